@@ -1,12 +1,21 @@
 package agent
 
 import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/knowledge"
 	"ai-agent-api-discovery/llm"
 	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/prompts"
 	"ai-agent-api-discovery/utils"
+	"ai-agent-api-discovery/valuegen"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -26,15 +35,50 @@ type FieldTestStatus struct {
 // DeepseekAgent orchestrates the API discovery process using LLM
 type DeepseekAgent struct {
 	request            models.DiscoverRequest
-	conversation       []models.Message
+	ctx                context.Context   // cancelled when the run is abandoned
+	systemTemplate     string            // template of the system prompt variant chosen for this run
+	promptVersions     map[string]string // version of each prompt template the run rendered
+	hintedRequired     []string          // fields the hinted or documented schema lists as required
+	spec               *specCheck        // OpenAPI operation being verified, if any
+	confirmedRequired  map[string]bool   // fields whose removal from a working body failed
+	priorFields        []string          // fields known before the run, as described to the LLM
+	history            []*turn           // every iteration so far, for the prompt
+	turn               *turn             // the current iteration
+	traces             []models.ReasoningTrace
 	knownFields        map[string]*models.FieldInfo
 	fieldStatus        map[string]*FieldTestStatus
 	currentBody        map[string]interface{}
 	minimalSuccessBody map[string]interface{} // Stores the smallest working request body
 	iterations         int
 	llmClient          *llm.DeepseekClient
+	target             *utils.TargetClient // sends requests to the target API and records them
+	headers            map[string]string   // request headers, including those set by the LLM
+	lastRequestBody    interface{}         // body of the last request sent for the LLM
+	lastResponse       *models.HTTPResponse
+	errorRules         []errparse.Dialect // custom error rules, tried before the built-in dialects
+	probes             int                // direct probes sent outside the LLM loop
+	relationships      []models.FieldRelationship
+	discriminator      string                 // field selecting between body variants, if any
+	variants           []models.SchemaVariant // field sets discovered per discriminator value
+	variantFields      map[string]bool        // fields that belong to specific variants only
+	values             *valuegen.Generator    // test values, seeded per run
+	suggestions        map[string]interface{} // generated value shown to the LLM per field, type and format
+	hostKnowledge      *knowledge.Host        // what earlier runs learned about the target host, if anything
+	knowledgeNotes     []string               // the host's knowledge as described to the LLM
+	dialects           map[string]int         // error responses parsed per error dialect
+	// additionalProperties records per object path ("" for the top level)
+	// whether the target accepts unknown fields
+	additionalProperties map[string]*bool
 }
 
+// maxActionRepairs is how many times a malformed reply is sent back to the
+// LLM for repair within one iteration
+const maxActionRepairs = 2
+
+// maxLLMFailures is how many iterations in a row may be lost to an
+// unavailable LLM before the run is aborted
+const maxLLMFailures = 3
+
 // NewDeepseekAgent creates a new instance of DeepseekAgent
 func NewDeepseekAgent(req models.DiscoverRequest) (*DeepseekAgent, error) {
 	client, err := llm.NewDeepseekClient()
@@ -42,141 +86,156 @@ func NewDeepseekAgent(req models.DiscoverRequest) (*DeepseekAgent, error) {
 		return nil, fmt.Errorf("failed to create Deepseek client: %w", err)
 	}
 
-	return &DeepseekAgent{
-		request:            req,
-		conversation:       []models.Message{},
-		knownFields:        make(map[string]*models.FieldInfo),
-		fieldStatus:        make(map[string]*FieldTestStatus),
-		currentBody:        req.InitialBody,
-		minimalSuccessBody: make(map[string]interface{}),
-		iterations:         0,
-		llmClient:          client,
-	}, nil
+	// Request rules take precedence over the shared rules for the target host
+	rules := append(append([]models.ErrorRule(nil), req.ErrorRules...), errparse.RulesForURL(req.URL)...)
+	errorRules, err := errparse.CompileRules(rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile error rules: %w", err)
+	}
+	client.SetBudget(req.Budget)
+	settings, err := llm.ResolveSettings(req.LLM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LLM settings: %w", err)
+	}
+	client.Configure(settings)
+	systemTemplate, err := prompts.System(req.LLM.PromptVariant)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LLM settings: %w", err)
+	}
+
+	a := &DeepseekAgent{
+		request:              req,
+		systemTemplate:       systemTemplate,
+		promptVersions:       make(map[string]string),
+		knownFields:          make(map[string]*models.FieldInfo),
+		fieldStatus:          make(map[string]*FieldTestStatus),
+		currentBody:          req.InitialBody,
+		minimalSuccessBody:   make(map[string]interface{}),
+		iterations:           0,
+		ctx:                  context.Background(),
+		llmClient:            client,
+		target:               utils.NewTargetClient(req.Target),
+		headers:              cloneHeaders(req.Headers),
+		errorRules:           errorRules,
+		values:               valuegen.New(valueSeed(req.ValueSeed)),
+		suggestions:          make(map[string]interface{}),
+		additionalProperties: make(map[string]*bool),
+		confirmedRequired:    make(map[string]bool),
+		dialects:             make(map[string]int),
+	}
+	if err := a.seedFromSpec(); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI seed: %w", err)
+	}
+	a.seedFromHints()
+	a.seedFromKnowledge()
+	return a, nil
 }
 
-// RunDiscovery executes the main discovery loop
-func (a *DeepseekAgent) RunDiscovery() (*models.DiscoveredSchema, error) {
-	utils.Logger.Printf("Starting discovery for %s %s", a.request.Method, a.request.URL)
+// valueSeed returns the request's seed for test values, or a random one
+func valueSeed(seed *int64) int64 {
+	if seed != nil {
+		return *seed
+	}
+	return time.Now().UnixNano()
+}
 
-	// Initialize with system prompt
-	a.addSystemMessage(`You are an AI agent that discovers API schemas through intelligent interaction.
-Your goal is to understand the structure and requirements of any API endpoint through systematic testing.
-
-IMPORTANT: Always respond with a valid JSON object in this format:
-{
-    "action": "modify_fields",
-    "body": {
-        "field1": "value1",
-        "field2": "value2"
-    },
-    "explanation": "Reasoning behind these changes"
-}
-
-Key Strategies:
-
-1. Progressive Discovery:
-   - Start with minimal, common fields
-   - Add fields based on error messages
-   - Use semantic naming to guess related fields
-   - Consider API context (e.g., /users endpoint likely needs email/username)
-
-2. Smart Value Selection:
-   - Use contextually appropriate test values
-   - Match values to field names (e.g., "email" → valid email format)
-   - Consider common validation patterns
-   - Test edge cases when appropriate
-
-3. Array/Batch Handling:
-   - For batch endpoints, try both single and multiple items
-   - Test array wrapper keys: "items", "data", "records", etc.
-   - Ensure consistent field structure across array items
-
-4. Error Analysis:
-   - Extract field names from error messages
-   - Identify validation requirements
-   - Look for type hints in errors
-   - Parse both structured and unstructured errors
-
-5. Type Detection:
-   - Infer types from successful responses
-   - Consider field name conventions
-   - Test multiple value formats
-   - Look for format-specific patterns
-
-6. Field Requirements:
-   - Mark fields as required only when explicitly indicated
-   - Consider API context for likely requirements
-   - Test removal of fields to verify requirements
-   - Watch for dependent field relationships
-
-When you want to indicate completion:
-{
-    "action": "complete",
-    "body": {},
-    "explanation": "Schema discovery is complete"
-}
-
-Complete when:
-1. We've made at least one successful request
-2. We've identified all required fields
-3. We understand the basic structure
-4. We can handle any array/batch requirements
-
-Remember:
-- Different APIs have different patterns
-- Error messages vary in format and detail
-- Some fields may be server-generated
-- Requirements might depend on API context
-- Security fields need special handling`)
-
-	// Add initial user message
-	initialMsg := fmt.Sprintf("We are calling %s %s. "+
-		"We start with %s body. Please propose next steps.",
-		a.request.Method, a.request.URL,
-		func() string {
-			if len(a.currentBody) == 0 {
-				return "an empty"
-			}
-			return "the provided"
-		}())
-	utils.Logger.Printf("Initial message: %s", initialMsg)
-	a.addUserMessage(initialMsg)
+// RunDiscovery executes the main discovery loop and records what the run
+// learned in the knowledge base. The run stops, without a schema, once ctx
+// is done, e.g. when the client that asked for it disconnects.
+func (a *DeepseekAgent) RunDiscovery(ctx context.Context) (*models.DiscoveredSchema, error) {
+	a.ctx = ctx
+	a.llmClient.SetContext(ctx)
+	a.target.SetContext(ctx)
+	schema, err := a.discover()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		utils.Logger.Printf("Discovery of %s %s abandoned: %v", a.request.Method, a.request.URL, ctxErr)
+		schema, err = nil, fmt.Errorf("discovery abandoned: %w", ctxErr)
+	}
+	if err != nil {
+		schema = nil
+	}
+	a.remember(schema)
+	return schema, err
+}
 
+// discover runs the discovery loop
+func (a *DeepseekAgent) discover() (*models.DiscoveredSchema, error) {
+	utils.Logger.Printf("Starting discovery for %s %s", a.request.Method, a.request.URL)
+
+	llmFailures, freeActions := 0, 0
 	for a.iterations < a.request.MaxIterations {
+		if err := a.ctx.Err(); err != nil {
+			return nil, err
+		}
 		utils.Logger.Printf("\n=== Iteration %d/%d ===", a.iterations+1, a.request.MaxIterations)
 		a.iterations++
+		a.beginTurn()
 
 		// Get next action from LLM
 		utils.Logger.Printf("Getting next action from LLM...")
 		nextAction, err := a.askLLMForNextAction()
+		if errors.Is(err, llm.ErrTransient) && llmFailures < maxLLMFailures {
+			// Retries and fallbacks are exhausted for now; spend the iteration
+			// rather than the run
+			llmFailures++
+			utils.Logger.Printf("LLM unavailable (%d/%d), skipping iteration: %v", llmFailures, maxLLMFailures, err)
+			continue
+		}
+		if errors.Is(err, llm.ErrInvalidAction) {
+			// Even the repaired replies were unusable; try again next iteration
+			utils.Logger.Printf("No usable action this iteration: %v", err)
+			a.note("Your replies did not contain a valid action and were ignored.")
+			continue
+		}
+		if errors.Is(err, llm.ErrBudget) {
+			utils.Logger.Printf("Aborting discovery: %v", err)
+			return nil, fmt.Errorf("discovery aborted: %w", err)
+		}
 		if err != nil {
 			utils.Logger.Printf("Error getting next action: %v", err)
 			return nil, fmt.Errorf("failed to get next action: %w", err)
 		}
-		actionBytes, _ := json.MarshalIndent(nextAction, "", "  ")
-		utils.Logger.Printf("LLM suggested action:\n%s", string(actionBytes))
+		llmFailures = 0
+		utils.Logger.Printf("LLM suggested action:\n%s", llm.EncodeAction(nextAction))
+		a.turn.action = nextAction
 
 		// Check if we're done
-		if action, ok := nextAction["action"].(string); ok && action == "complete" {
+		if _, done := nextAction.(*llm.Complete); done {
 			if !a.isDiscoveryComplete() {
 				incompleteMsg := "Cannot complete yet. Some fields still need testing. " + a.getIncompleteFieldsMessage()
 				utils.Logger.Printf("Completion rejected: %s", incompleteMsg)
-				a.addSystemMessage(incompleteMsg)
+				a.note(incompleteMsg)
 				continue
 			}
 			utils.Logger.Printf("Discovery complete! Building final schema...")
 			return a.buildSchema(), nil
 		}
 
-		// Execute the HTTP request
-		utils.Logger.Printf("Executing HTTP request with body: %+v", nextAction["body"])
-		response, err := a.executeHTTP(nextAction)
+		// Run the action
+		result, err := a.runAction(nextAction)
+		if errors.Is(err, utils.ErrCircuitOpen) {
+			utils.Logger.Printf("Aborting discovery: %v", err)
+			return nil, fmt.Errorf("discovery aborted: %w", err)
+		}
 		if err != nil {
 			errMsg := fmt.Sprintf("HTTP call failed: %v", err)
 			utils.Logger.Print(errMsg)
-			a.addSystemMessage(errMsg)
+			a.note(errMsg)
 			continue
 		}
+		if result.response == nil {
+			utils.Logger.Printf("Action result: %s", result.observation)
+			a.note(result.observation)
+			// Actions that send nothing are cheap, so a few in a row do not
+			// use up an iteration
+			if freeActions < maxFreeActions {
+				freeActions++
+				a.iterations--
+			}
+			continue
+		}
+		freeActions = 0
+		response := result.response
 
 		// Handle the response
 		if response.StatusCode >= 200 && response.StatusCode < 300 {
@@ -209,11 +268,6 @@ Remember:
 				status.SuccessfulTests,
 				status.SuccessfulTests+status.FailedTests)
 		}
-
-		// Add status update to conversation
-		statusMsg := a.getFieldStatusMessage()
-		utils.Logger.Printf("\nStatus update: %s", statusMsg)
-		a.addSystemMessage(statusMsg)
 	}
 
 	utils.Logger.Printf("Max iterations (%d) reached without completing discovery", a.request.MaxIterations)
@@ -257,50 +311,87 @@ func (a *DeepseekAgent) getFieldStatusMessage() string {
 		if fieldStatus == nil {
 			continue
 		}
-		status = append(status, fmt.Sprintf(
-			"%s (type: %s, in minimal set: %v, tests: %d/%d)",
+		line := fmt.Sprintf(
+			"- %s (type: %s, in minimal set: %v, tests: %d/%d)",
 			fieldName,
 			info.Type,
 			fieldStatus.IsInMinimalSet,
 			fieldStatus.SuccessfulTests,
 			fieldStatus.SuccessfulTests+fieldStatus.FailedTests,
-		))
+		)
+		if _, sent := a.currentBody[fieldName]; !sent && info.SampleValue == nil {
+			line += ", try: " + toJSON(a.suggestedValue(*info))
+		}
+		status = append(status, line)
 	}
-	return fmt.Sprintf("Current field status:\n%v", status)
+	if len(status) == 0 {
+		return "Current field status: no fields known yet"
+	}
+	// Sorted so the prompt stays stable between turns
+	sort.Strings(status)
+	return "Current field status:\n" + strings.Join(status, "\n")
 }
 
-// askLLMForNextAction gets the next action from the LLM
-func (a *DeepseekAgent) askLLMForNextAction() (map[string]interface{}, error) {
-	// Get completion from Deepseek using R1 model for better reasoning
-	response, err := a.llmClient.CompleteWithR1(a.conversation)
+// askLLMForNextAction gets the next action from the LLM. A reply that does
+// not hold a valid action is sent back with the problem, up to
+// maxActionRepairs times, before giving up on this iteration.
+func (a *DeepseekAgent) askLLMForNextAction() (llm.Action, error) {
+	messages, err := a.buildPrompt()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get LLM completion: %w", err)
+		return nil, fmt.Errorf("failed to build prompt: %w", err)
 	}
+	for attempt := 0; ; attempt++ {
+		action, response, err := a.llmClient.CompleteAction(messages)
+		if response == nil {
+			return nil, fmt.Errorf("failed to get LLM completion: %w", err)
+		}
 
-	// Add the response to our conversation
-	a.addAssistantMessage(response.Content)
+		a.recordTrace(attempt, action, response, err)
 
-	// Parse the action from the response
-	action, err := a.llmClient.ParseAction(response.Content)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse LLM action: %w", err)
+		// Repairs follow the reply they refer to; they are not kept across
+		// iterations. The reasoning is never sent back.
+		messages = append(messages, models.Message{Role: response.Role, Content: response.Content})
+
+		if err == nil {
+			return action, nil
+		}
+		if attempt >= maxActionRepairs {
+			return nil, fmt.Errorf("failed to parse LLM action: %w", err)
+		}
+
+		utils.Logger.Printf("Asking the LLM to repair its reply (%d/%d): %v", attempt+1, maxActionRepairs, err)
+		repair, renderErr := a.render(prompts.Repair, prompts.RepairData{Error: err.Error()})
+		if renderErr != nil {
+			return nil, fmt.Errorf("failed to build prompt: %w", renderErr)
+		}
+		messages = append(messages, models.Message{Role: "user", Content: repair})
 	}
+}
 
-	return action, nil
+// recordTrace keeps the reasoning behind a reply for the run record
+func (a *DeepseekAgent) recordTrace(attempt int, action llm.Action, response *models.Message, err error) {
+	if response.Reasoning == "" {
+		return
+	}
+	trace := models.ReasoningTrace{Iteration: a.iterations, Attempt: attempt, Reasoning: response.Reasoning}
+	if err != nil {
+		trace.Error = err.Error()
+	} else {
+		trace.Action = encodeActionCompact(action)
+	}
+	a.traces = append(a.traces, trace)
 }
 
-// executeHTTP performs the HTTP request with the current state
-func (a *DeepseekAgent) executeHTTP(action map[string]interface{}) (*models.HTTPResponse, error) {
-	body, ok := action["body"].(map[string]interface{})
-	if !ok {
+// executeHTTP sends body, or the current body if nil, to the target
+func (a *DeepseekAgent) executeHTTP(body map[string]interface{}) (*models.HTTPResponse, error) {
+	if body == nil {
 		body = a.currentBody
 	}
 
 	var requestBody interface{} = body
 
 	// Check if this is a batch/array endpoint
-	if strings.Contains(strings.ToLower(a.request.URL), "batch") ||
-		strings.Contains(strings.ToLower(a.request.URL), "bulk") {
+	if isBatchURL(a.request.URL) {
 		// Try to construct an array request
 		if singleItem, ok := body["item"].(map[string]interface{}); ok {
 			// If "item" is provided, use it as template
@@ -317,7 +408,7 @@ func (a *DeepseekAgent) executeHTTP(action map[string]interface{}) (*models.HTTP
 				if arr, isArray := v.([]interface{}); isArray {
 					utils.Logger.Printf("Detected array payload with key '%s', trying both formats", key)
 					// Try direct array first
-					resp, err := utils.DoRequest(a.request.Method, a.request.URL, a.request.Headers, arr)
+					resp, err := a.sendAction(arr)
 					if err == nil && resp.StatusCode < 400 {
 						return resp, nil
 					}
@@ -329,22 +420,48 @@ func (a *DeepseekAgent) executeHTTP(action map[string]interface{}) (*models.HTTP
 		}
 	}
 
-	// Update current body
-	if bodyMap, isMap := requestBody.(map[string]interface{}); isMap {
-		if a.currentBody == nil {
-			a.currentBody = make(map[string]interface{})
-		}
-		for k, v := range bodyMap {
-			a.currentBody[k] = v
+	// Use fresh values for fields that must be unique
+	switch b := requestBody.(type) {
+	case map[string]interface{}:
+		requestBody = a.freshenBody(b)
+	case []interface{}:
+		for i, item := range b {
+			if itemMap, isMap := item.(map[string]interface{}); isMap {
+				b[i] = a.freshenBody(itemMap)
+			}
 		}
 	}
 
-	return utils.DoRequest(
-		a.request.Method,
-		a.request.URL,
-		a.request.Headers,
-		requestBody,
-	)
+	// The body sent becomes the current body that later actions build on
+	if bodyMap, isMap := requestBody.(map[string]interface{}); isMap {
+		a.currentBody = bodyMap
+	} else {
+		a.currentBody = cloneBody(body)
+	}
+
+	return a.sendAction(requestBody)
+}
+
+// sendAction sends a request on behalf of the LLM and remembers the exchange
+// for inspect_last_response
+func (a *DeepseekAgent) sendAction(requestBody interface{}) (*models.HTTPResponse, error) {
+	requestBody, removed := a.withoutForbidden(requestBody)
+	if len(removed) > 0 {
+		msg := fmt.Sprintf("Removed %s before sending: the hints say never to send them", strings.Join(removed, ", "))
+		utils.Logger.Print(msg)
+		a.note(msg)
+		a.currentBody = deepCopyBody(a.currentBody)
+		for _, path := range removed {
+			deletePath(a.currentBody, path)
+		}
+	}
+	resp, err := a.target.Do(models.PurposeAction, a.request.Method, a.request.URL, a.headers, requestBody)
+	if err == nil {
+		a.lastRequestBody = requestBody
+		a.lastResponse = resp
+		a.recordExchange(requestBody, resp)
+	}
+	return resp, err
 }
 
 // handleSuccess processes a successful API response
@@ -372,6 +489,7 @@ func (a *DeepseekAgent) handleSuccess(resp *models.HTTPResponse) (*models.Discov
 			status.IsInMinimalSet = true
 			status.SuccessfulTests++
 		}
+		a.confirmHint(fieldName)
 	}
 
 	// Identify server-generated fields
@@ -384,6 +502,19 @@ func (a *DeepseekAgent) handleSuccess(resp *models.HTTPResponse) (*models.Discov
 		}
 	}
 
+	// Probe the working body for unique fields first, so the other probes
+	// are not rejected as duplicates, then for unknown-field strictness (which
+	// decides how much a 2xx proves), relationships, null/empty handling and
+	// variants. The claims of an OpenAPI operation are checked next; guessing
+	// undocumented fields is the least certain, so it gets whatever budget is left.
+	a.detectUniqueness()
+	a.probeAdditionalProperties()
+	a.discoverRelationships()
+	a.probeEmptyValues()
+	a.discoverVariants()
+	a.verifySpec()
+	a.discoverHiddenFields()
+
 	// Log success
 	utils.Logger.Printf("Successfully discovered minimal field set!")
 	utils.Logger.Printf("Minimal request body that succeeded: %+v", a.minimalSuccessBody)
@@ -396,6 +527,24 @@ func (a *DeepseekAgent) handleSuccess(resp *models.HTTPResponse) (*models.Discov
 func (a *DeepseekAgent) handleErrorResponse(resp *models.HTTPResponse) {
 	errorText := string(resp.ResponseBody)
 
+	// Try the custom error rules and framework-specific error dialects first
+	var extracted string
+	recognised := false
+	contentType := http.Header(resp.Headers).Get("Content-Type")
+	if result := errparse.ParseWithRules(a.errorRules, contentType, resp.ResponseBody); result != nil {
+		utils.Logger.Printf("Error response matched %s dialect with %d field errors", result.Dialect, len(result.Errors))
+		a.countDialect(result)
+		a.applyFieldErrors(result.Errors)
+		if len(result.Errors) > 0 {
+			recognised = true
+			extracted = "\nExtracted field errors (" + result.Dialect + "):" + formatFieldErrors(result.Errors)
+		}
+		// Problem documents carry a lot of boilerplate, so send the summary instead
+		if result.Summary != "" {
+			errorText = result.Summary
+		}
+	}
+
 	// Try to parse structured error response
 	var errorResp struct {
 		Error            string            `json:"error"`
@@ -406,29 +555,103 @@ func (a *DeepseekAgent) handleErrorResponse(resp *models.HTTPResponse) {
 	if err := json.Unmarshal(resp.ResponseBody, &errorResp); err == nil {
 		// Handle structured error response
 		if errorResp.Error != "" {
-			a.analyzeErrorMessage(errorResp.Error)
+			recognised = a.analyzeErrorMessage(errorResp.Error) || recognised
 		}
 		if len(errorResp.Errors) > 0 {
 			for _, err := range errorResp.Errors {
-				a.analyzeErrorMessage(err)
+				recognised = a.analyzeErrorMessage(err) || recognised
 			}
 		}
 		if len(errorResp.ValidationErrors) > 0 {
+			recognised = true
 			for field, err := range errorResp.ValidationErrors {
 				a.updateFieldFromError(field, err)
 			}
 		}
 	} else {
 		// Handle plain text error
-		a.analyzeErrorMessage(errorText)
+		recognised = a.analyzeErrorMessage(errorText) || recognised
+	}
+
+	// Fall back to asking the chat model when no pattern recognised the error
+	if !recognised && resp.StatusCode >= 400 && resp.StatusCode < 500 && len(resp.ResponseBody) > 0 {
+		if errs := a.extractFieldErrorsWithLLM(resp); len(errs) > 0 {
+			a.applyFieldErrors(errs)
+			extracted = "\nExtracted field errors (llm):" + formatFieldErrors(errs)
+		}
+	}
+
+	// A conflict means the body is valid but its values are already taken
+	if resp.StatusCode == http.StatusConflict {
+		a.note(fmt.Sprintf("Got conflict response (status %d): %s\n"+
+			"The body structure was accepted but a value is already in use. Keep the same fields; "+
+			"fields marked unique get fresh values automatically.%s", resp.StatusCode, errorText, extracted))
+		return
+	}
+
+	a.note(fmt.Sprintf("Got error response (status %d): %s\nAnalyzed error message for field requirements.%s",
+		resp.StatusCode, errorText, extracted))
+}
+
+// extractFieldErrorsWithLLM asks the chat model to extract field errors from an
+// error body that none of the dialects or patterns recognised
+func (a *DeepseekAgent) extractFieldErrorsWithLLM(resp *models.HTTPResponse) []errparse.FieldError {
+	utils.Logger.Printf("No error pattern matched, asking LLM to extract field errors")
+	a.promptVersions[prompts.Extraction] = prompts.Version(prompts.Extraction)
+	problems, err := a.llmClient.ExtractFieldProblems(resp.StatusCode, string(resp.ResponseBody))
+	if err != nil {
+		utils.Logger.Printf("LLM error extraction failed: %v", err)
+		return nil
+	}
+
+	errs := make([]errparse.FieldError, 0, len(problems))
+	for _, p := range problems {
+		errs = append(errs, fieldErrorFromProblem(p))
 	}
+	return errs
+}
 
-	a.addSystemMessage(fmt.Sprintf("Got error response (status %d): %s\nAnalyzed error message for field requirements.",
-		resp.StatusCode, errorText))
+// fieldErrorFromProblem maps an LLM-extracted field problem onto a dialect field error
+func fieldErrorFromProblem(p llm.FieldProblem) errparse.FieldError {
+	fe := errparse.FieldError{
+		Path:    p.Path,
+		Rule:    errparse.RuleInvalid,
+		Param:   p.Constraint,
+		Message: fmt.Sprintf("%s (llm)", p.Problem),
+	}
+	switch p.Problem {
+	case llm.ProblemMissing:
+		fe.Rule, fe.Param = errparse.RuleRequired, ""
+	case llm.ProblemWrongType:
+		fe.Rule, fe.Param = errparse.RuleType, p.ExpectedType
+	case llm.ProblemInvalidValue:
+		if p.Constraint != "" {
+			fe.Rule = errparse.RuleEnum
+		}
+	case llm.ProblemInvalidFormat:
+		fe.Rule = errparse.RuleFormat
+		if strings.ContainsAny(p.Constraint, `^$\[(`) {
+			fe.Rule = errparse.RulePattern
+		}
+	case llm.ProblemTooSmall:
+		fe.Rule = errparse.RuleMin
+	case llm.ProblemTooLarge:
+		fe.Rule = errparse.RuleMax
+	case llm.ProblemTooShort:
+		fe.Rule = errparse.RuleMinLength
+	case llm.ProblemTooLong:
+		fe.Rule = errparse.RuleMaxLength
+	case llm.ProblemDuplicate:
+		fe.Rule = errparse.RuleUnique
+	case llm.ProblemUnknownField:
+		fe.Rule = errparse.RuleUnknown
+	}
+	return fe
 }
 
 // analyzeErrorMessage tries to extract field information from error messages
-func (a *DeepseekAgent) analyzeErrorMessage(errMsg string) {
+// and reports whether any pattern matched
+func (a *DeepseekAgent) analyzeErrorMessage(errMsg string) bool {
 	// Common patterns for required field errors
 	patterns := []struct {
 		regex   *regexp.Regexp
@@ -457,11 +680,14 @@ func (a *DeepseekAgent) analyzeErrorMessage(errMsg string) {
 		},
 	}
 
+	matched := false
 	for _, pattern := range patterns {
 		if matches := pattern.regex.FindStringSubmatch(errMsg); matches != nil {
 			pattern.handler(matches)
+			matched = true
 		}
 	}
+	return matched
 }
 
 // updateFieldFromError updates field information based on validation error
@@ -506,6 +732,120 @@ func (a *DeepseekAgent) updateFieldFromError(field, errMsg string) {
 	}
 }
 
+// applyFieldErrors updates field information from errors extracted by an error dialect
+func (a *DeepseekAgent) applyFieldErrors(errs []errparse.FieldError) {
+	for _, fe := range errs {
+		if fe.Path == "" {
+			continue
+		}
+
+		switch fe.Rule {
+		case errparse.RuleRequired:
+			a.markFieldRequired(fe.Path)
+		case errparse.RuleUnknown:
+			a.markFieldUnknown(fe.Path)
+			continue
+		case errparse.RuleUnique:
+			a.markFieldUnique(fe.Path)
+		case errparse.RuleType:
+			a.markFieldKnown(fe.Path)
+			a.markFieldTypeInvalid(fe.Path)
+			if fe.Param != "" {
+				a.knownFields[fe.Path].Type = fe.Param
+			}
+		default:
+			// A rejected value says what the field accepts, not that it is required
+			a.markFieldKnown(fe.Path)
+			applyConstraint(a.knownFields[fe.Path], fe)
+		}
+
+		if status, exists := a.fieldStatus[fe.Path]; exists && fe.Message != "" {
+			status.ValidationErrors = append(status.ValidationErrors, fe.Message)
+		}
+	}
+}
+
+// applyConstraint records the constraint described by a field error on info
+func applyConstraint(info *models.FieldInfo, fe errparse.FieldError) {
+	switch fe.Rule {
+	case errparse.RuleEnum:
+		if fe.Param != "" {
+			info.Enum = splitEnumValues(fe.Param)
+		}
+	case errparse.RuleFormat:
+		info.Format = fe.Param
+	case errparse.RulePattern:
+		if fe.Param != "" {
+			info.Pattern = fe.Param
+		}
+	case errparse.RuleMin, errparse.RuleMax, errparse.RuleMinLength, errparse.RuleMaxLength, errparse.RuleRange:
+		lower, upper := fe.Param, ""
+		if fe.Rule == errparse.RuleRange {
+			lower, upper, _ = strings.Cut(fe.Param, "..")
+		} else if fe.Rule == errparse.RuleMax || fe.Rule == errparse.RuleMaxLength {
+			lower, upper = "", fe.Param
+		}
+		isLength := fe.Rule == errparse.RuleMinLength || fe.Rule == errparse.RuleMaxLength ||
+			(fe.Rule == errparse.RuleRange && (info.Type == "string" || strings.HasPrefix(info.Type, "array")))
+		if n, err := strconv.ParseFloat(lower, 64); err == nil {
+			if isLength {
+				length := int(n)
+				info.MinLength = &length
+			} else {
+				info.Minimum = &n
+			}
+		}
+		if n, err := strconv.ParseFloat(upper, 64); err == nil {
+			if isLength {
+				length := int(n)
+				info.MaxLength = &length
+			} else {
+				info.Maximum = &n
+			}
+		}
+	}
+}
+
+// splitEnumValues splits an enum parameter such as "'a' or 'b'" or "a, b, c" into values
+func splitEnumValues(param string) []string {
+	fields := strings.FieldsFunc(param, func(r rune) bool {
+		return r == ',' || r == '|' || r == ' '
+	})
+	var values []string
+	for _, f := range fields {
+		f = strings.Trim(f, `'"[]`)
+		if f == "" || f == "or" || f == "and" {
+			continue
+		}
+		values = append(values, f)
+	}
+	return values
+}
+
+// formatFieldErrors renders extracted field errors as a list for the conversation
+func formatFieldErrors(errs []errparse.FieldError) string {
+	var b strings.Builder
+	for _, fe := range errs {
+		path := fe.Path
+		if path == "" {
+			path = "(body)"
+		}
+		b.WriteString("\n- " + path + ": " + fe.Rule)
+		if fe.Param != "" {
+			b.WriteString(" (" + fe.Param + ")")
+		}
+	}
+	return b.String()
+}
+
+// markFieldUnknown drops a field the target reported as unknown
+func (a *DeepseekAgent) markFieldUnknown(field string) {
+	utils.Logger.Printf("Target rejected unknown field '%s'", field)
+	delete(a.currentBody, field)
+	delete(a.knownFields, field)
+	delete(a.fieldStatus, field)
+}
+
 // markFieldRequired marks a field as potentially part of minimal set
 func (a *DeepseekAgent) markFieldRequired(field string) {
 	if _, exists := a.knownFields[field]; !exists {
@@ -513,6 +853,7 @@ func (a *DeepseekAgent) markFieldRequired(field string) {
 			Name: field,
 		}
 	}
+	a.confirmHint(field)
 
 	if status, exists := a.fieldStatus[field]; exists {
 		status.IsInMinimalSet = true
@@ -524,6 +865,23 @@ func (a *DeepseekAgent) markFieldRequired(field string) {
 	}
 }
 
+// markFieldKnown records a field the target validated without adding it to
+// the minimal set
+func (a *DeepseekAgent) markFieldKnown(field string) {
+	if _, exists := a.knownFields[field]; !exists {
+		a.knownFields[field] = &models.FieldInfo{
+			Name: field,
+		}
+	}
+	a.confirmHint(field)
+
+	if _, exists := a.fieldStatus[field]; !exists {
+		a.fieldStatus[field] = &FieldTestStatus{
+			IsDiscovered: true,
+		}
+	}
+}
+
 // markFieldTypeInvalid marks that we need to try a different type for the field
 func (a *DeepseekAgent) markFieldTypeInvalid(field string) {
 	if status, exists := a.fieldStatus[field]; exists {
@@ -724,22 +1082,29 @@ func (a *DeepseekAgent) buildSchema() *models.DiscoveredSchema {
 
 	// First add fields from the minimal success body
 	for fieldName, value := range a.minimalSuccessBody {
+		// Variant-specific fields are reported with their variant
+		if a.variantFields[fieldName] {
+			continue
+		}
 		if info, exists := a.knownFields[fieldName]; exists {
 			fieldInfo := *info
 			fieldInfo.IsInMinimalSet = true
 			fieldInfo.SampleValue = value
+			fieldInfo.AdditionalProperties = a.additionalProperties[fieldName]
 			fields = append(fields, fieldInfo)
 		}
 	}
 
 	// Then add any other discovered fields
 	for fieldName, info := range a.knownFields {
-		// Skip if already added from minimal set
-		if _, inMinimal := a.minimalSuccessBody[fieldName]; inMinimal {
+		// Skip if already added from minimal set or reported with a variant
+		if _, inMinimal := a.minimalSuccessBody[fieldName]; inMinimal || a.variantFields[fieldName] {
 			continue
 		}
 
 		fieldInfo := *info
+		// Object fields at any level carry what probing found about unknown fields
+		fieldInfo.AdditionalProperties = a.additionalProperties[fieldName]
 		if status, exists := a.fieldStatus[fieldName]; exists {
 			fieldInfo.IsInMinimalSet = status.IsInMinimalSet
 			fieldInfo.TestResults = &models.TestResults{
@@ -753,32 +1118,51 @@ func (a *DeepseekAgent) buildSchema() *models.DiscoveredSchema {
 		fields = append(fields, fieldInfo)
 	}
 
-	return &models.DiscoveredSchema{
-		Fields:             fields,
-		MinimalRequestBody: a.minimalSuccessBody,
+	// Nested objects that were probed for unknown fields but are not known
+	// fields themselves are still reported, so every level carries its result
+	reported := make(map[string]bool, len(fields))
+	for _, info := range fields {
+		reported[info.Name] = true
+	}
+	for _, path := range sortedPaths(a.additionalProperties) {
+		if path == "" || reported[path] || a.variantFields[path] {
+			continue
+		}
+		value, inMinimal := valueAt(a.minimalSuccessBody, path)
+		fields = append(fields, models.FieldInfo{
+			Name:                 path,
+			Type:                 "object",
+			SampleValue:          value,
+			IsInMinimalSet:       inMinimal,
+			AdditionalProperties: a.additionalProperties[path],
+		})
 	}
-}
-
-// addSystemMessage adds a system message to the conversation
-func (a *DeepseekAgent) addSystemMessage(content string) {
-	a.conversation = append(a.conversation, models.Message{
-		Role:    "system",
-		Content: content,
-	})
-}
 
-// addUserMessage adds a user message to the conversation
-func (a *DeepseekAgent) addUserMessage(content string) {
-	a.conversation = append(a.conversation, models.Message{
-		Role:    "user",
-		Content: content,
-	})
+	return &models.DiscoveredSchema{
+		Fields:               fields,
+		MinimalRequestBody:   a.minimalSuccessBody,
+		Relationships:        a.relationships,
+		Discriminator:        a.discriminator,
+		Variants:             a.variants,
+		AdditionalProperties: a.additionalProperties[""],
+		Run:                  a.RunRecord(),
+		Drift:                a.driftReport(),
+	}
 }
 
-// addAssistantMessage adds an assistant message to the conversation
-func (a *DeepseekAgent) addAssistantMessage(content string) {
-	a.conversation = append(a.conversation, models.Message{
-		Role:    "assistant",
-		Content: content,
-	})
+// RunRecord returns what the run has sent to the target and spent on the LLM
+// so far. It is also useful after RunDiscovery has failed.
+func (a *DeepseekAgent) RunRecord() *models.RunRecord {
+	record := a.target.Record()
+	record.LLM = a.llmClient.Usage()
+	record.Traces = append([]models.ReasoningTrace(nil), a.traces...)
+	record.Prompts = make(map[string]string, len(a.promptVersions))
+	for name, version := range a.promptVersions {
+		record.Prompts[name] = version
+	}
+	record.ValueSeed = a.values.Seed()
+	if a.hostKnowledge != nil {
+		record.KnowledgeHost = a.hostKnowledge.Host
+	}
+	return record
 }
@@ -0,0 +1,641 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"ai-agent-api-discovery/valuegen"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxEnumChecks caps the documented enum values tried per field
+const maxEnumChecks = 10
+
+// noBudget is the reason given for claims left untested
+const noBudget = "no probe budget left"
+
+// verifySpec tests the claims of the request's OpenAPI operation against the
+// working body: which fields are required, whether documented optional fields
+// are known to the target, and their types, enums, bounds and formats. Undocumented fields are
+// added when the report is built, after hidden-field discovery.
+func (a *DeepseekAgent) verifySpec() {
+	if a.spec == nil || len(a.minimalSuccessBody) == 0 {
+		return
+	}
+	utils.Logger.Printf("Verifying operation %s against the target", a.spec.operationID)
+	a.verifyRequired()
+	accepted := a.verifyOptionalFields()
+	a.verifyTypes()
+	a.verifyEnums(accepted)
+	a.verifyConstraints()
+	a.verifyAdditionalProperties()
+	utils.Logger.Printf("Operation %s: %d differences, %d claims verified, %d unchecked",
+		a.spec.operationID, len(a.spec.items), len(a.spec.verified), len(a.spec.unchecked))
+}
+
+// verifyRequired compares the documented required fields with the fields the
+// target cannot do without. A nested field is only required when its object
+// is sent, so it is checked only if the working body sends the object.
+func (a *DeepseekAgent) verifyRequired() {
+	spec, base := a.spec, a.minimalSuccessBody
+	for _, field := range spec.required {
+		claim := field + ": required"
+		if parent, _ := splitFieldPath(field); nestedObject(base, parent) == nil {
+			spec.uncheck(claim, "the working body does not send "+parent)
+			continue
+		}
+		if !containsPath(base, field) {
+			spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftRequiredNotEnforced,
+				Documented: "required", Observed: "optional", Detail: "the target accepted a body without it"})
+			continue
+		}
+		switch required, unchecked := a.removalFails(field); {
+		case unchecked != "":
+			spec.uncheck(claim, unchecked)
+		case required:
+			spec.verify(claim)
+		default:
+			spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftRequiredNotEnforced,
+				Documented: "required", Observed: "optional", Detail: "the target accepted the working body without it"})
+		}
+	}
+
+	for _, field := range fieldPaths(base, "") {
+		if containsString(spec.required, field) {
+			continue
+		}
+		if required, _ := a.removalFails(field); !required {
+			continue
+		}
+		if _, documented := spec.fields[field]; documented {
+			spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftOptionalRequired,
+				Documented: "optional", Observed: "required", Detail: "the target rejected the working body without it"})
+		} else {
+			spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftUndocumentedRequired,
+				Observed: "required", Detail: "not documented, but the target rejected the working body without it"})
+		}
+	}
+}
+
+// removalFails reports whether the target rejects the working body without a
+// field, given by its dotted path, or why that could not be told
+func (a *DeepseekAgent) removalFails(field string) (required bool, unchecked string) {
+	if a.confirmedRequired[field] {
+		return true, ""
+	}
+	if !a.canProbe() {
+		return false, noBudget
+	}
+	body := deepCopyBody(a.minimalSuccessBody)
+	deletePath(body, field)
+	resp, err := a.probe(body)
+	switch {
+	case err != nil:
+		return false, "probe failed: " + err.Error()
+	case isSuccess(resp):
+		return false, ""
+	case !a.removalRejected(resp, field):
+		return false, "rejected for another reason: " + a.rejectionReason(resp)
+	}
+	a.confirmedRequired[field] = true
+	return true, ""
+}
+
+// verifyOptionalFields adds each documented field the working body leaves out
+// and checks the target accepts it. It returns the fields that were accepted
+// with the value that was sent.
+func (a *DeepseekAgent) verifyOptionalFields() map[string]interface{} {
+	spec, base := a.spec, a.minimalSuccessBody
+	accepted := make(map[string]interface{})
+	for _, field := range spec.fieldNames() {
+		parent, _ := splitFieldPath(field)
+		if containsPath(base, field) || nestedObject(base, parent) == nil || a.forbidden(field) {
+			continue
+		}
+		claim := field + ": accepted"
+		if !a.canProbe() {
+			spec.uncheck(claim, noBudget)
+			continue
+		}
+		value := a.documentedValue(spec.fields[field])
+		resp, err := a.probe(withPath(base, field, value))
+		if err != nil {
+			spec.uncheck(claim, "probe failed: "+err.Error())
+			continue
+		}
+		if !isSuccess(resp) {
+			a.classifyRejection(claim, field, value, resp)
+			continue
+		}
+		accepted[field] = value
+		if a.acceptsUnknownFields(parent) && !containsPath(responseObject(resp), field) {
+			spec.uncheck(claim, "the target accepts unknown fields too")
+			continue
+		}
+		spec.verify(claim)
+		if fieldType := spec.fields[field].Type; fieldType != "" {
+			spec.verify(field + ": " + fieldType)
+		}
+	}
+	return accepted
+}
+
+// classifyRejection records why the target rejected a documented field sent
+// with a value of its documented type, or leaves the claim unchecked if the
+// rejection was about something else
+func (a *DeepseekAgent) classifyRejection(claim, field string, value interface{}, resp *models.HTTPResponse) {
+	spec := a.spec
+	info := spec.fields[field]
+	errs := a.probeErrors(resp)
+	for _, fe := range errs {
+		if fe.Path != field {
+			continue
+		}
+		switch fe.Rule {
+		case errparse.RuleUnknown:
+			spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftFieldRejected,
+				Documented: info.Type, Detail: "the target rejected it as an unknown field"})
+			return
+		case errparse.RuleType:
+			spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftTypeMismatch,
+				Documented: info.Type, Observed: fe.Param,
+				Detail: fmt.Sprintf("the target rejected %s as the wrong type", toJSON(value))})
+			return
+		case errparse.RuleEnum:
+			if containsString(info.Enum, fmt.Sprint(value)) {
+				spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftEnumValueRejected,
+					Documented: info.Enum, Observed: splitEnumValues(fe.Param),
+					Detail: fmt.Sprintf("the target rejected the documented value %s", toJSON(value))})
+				return
+			}
+		}
+	}
+
+	spec.uncheck(claim, "rejected for another reason: "+a.rejectionReason(resp))
+}
+
+// verifyTypes checks the documented type of each field of the working body.
+// A value of another type in the working body only shows the target is
+// lenient, so a value of the documented type is sent before reporting drift.
+func (a *DeepseekAgent) verifyTypes() {
+	spec, base := a.spec, a.minimalSuccessBody
+	for _, field := range spec.fieldNames() {
+		fieldType := spec.fields[field].Type
+		current, present := valueAt(base, field)
+		if !present || fieldType == "" || current == nil {
+			continue
+		}
+		claim := field + ": " + fieldType
+		if typeMatches(fieldType, current) {
+			spec.verify(claim)
+			continue
+		}
+		if !a.canProbe() {
+			spec.uncheck(claim, fmt.Sprintf("the working body sends a %s and %s", jsonType(current), noBudget))
+			continue
+		}
+		value := a.documentedValue(spec.fields[field])
+		resp, err := a.probe(withPath(base, field, value))
+		switch {
+		case err != nil:
+			spec.uncheck(claim, "probe failed: "+err.Error())
+		case isSuccess(resp):
+			spec.verify(claim)
+		default:
+			a.classifyRejection(claim, field, value, resp)
+		}
+	}
+}
+
+// verifyEnums sends every documented value of each enum field the target
+// accepts, then a value outside the enum
+func (a *DeepseekAgent) verifyEnums(accepted map[string]interface{}) {
+	spec := a.spec
+	for _, field := range spec.fieldNames() {
+		info := spec.fields[field]
+		if len(info.Enum) == 0 {
+			continue
+		}
+		base := a.minimalSuccessBody
+		if value, ok := accepted[field]; ok {
+			base = withPath(base, field, value)
+		} else if !containsPath(base, field) {
+			continue
+		}
+
+		// Each value counts only if the target answered it: errors and
+		// rejections for other reasons leave it unchecked
+		tried, accepted := 0, 0
+		for i, entry := range info.Enum {
+			if i == maxEnumChecks || !a.canProbe() {
+				spec.uncheck(fmt.Sprintf("%s: enum values from %s on", field, toJSON(entry)), noBudget)
+				break
+			}
+			tried++
+			value := valuegen.EnumValue(info.Type, entry)
+			claim := fmt.Sprintf("%s: enum value %s accepted", field, toJSON(value))
+			resp, err := a.probe(withPath(base, field, value))
+			if err != nil {
+				spec.uncheck(claim, "probe failed: "+err.Error())
+				continue
+			}
+			if isSuccess(resp) {
+				accepted++
+				continue
+			}
+			fe, ok := a.rejectionOf(resp, field)
+			if !ok {
+				spec.uncheck(claim, "rejected for another reason: "+a.rejectionReason(resp))
+				continue
+			}
+			observed := interface{}(fe.Message)
+			if fe.Rule == errparse.RuleEnum && fe.Param != "" {
+				observed = splitEnumValues(fe.Param)
+			}
+			spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftEnumValueRejected,
+				Documented: info.Enum, Observed: observed,
+				Detail: fmt.Sprintf("the target rejected the documented value %s", toJSON(value))})
+		}
+		if tried == len(info.Enum) && accepted == tried {
+			spec.verify(field + ": enum values accepted")
+		}
+
+		claim := field + ": enum enforced"
+		outside, ok := a.invalidValue(info, errparse.RuleEnum)
+		if !ok {
+			continue
+		}
+		if !a.canProbe() {
+			spec.uncheck(claim, noBudget)
+			continue
+		}
+		resp, err := a.probe(withPath(base, field, outside))
+		switch {
+		case err != nil:
+			spec.uncheck(claim, "probe failed: "+err.Error())
+		case isSuccess(resp):
+			spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftEnumNotEnforced,
+				Documented: info.Enum, Observed: outside,
+				Detail: "the target accepted a value outside the documented enum"})
+		default:
+			if _, ok := a.rejectionOf(resp, field); ok {
+				spec.verify(claim)
+			} else {
+				spec.uncheck(claim, "rejected for another reason: "+a.rejectionReason(resp))
+			}
+		}
+	}
+}
+
+// constraint is a documented bound or format, the edge case that should be
+// accepted on it and the rule of the value beyond it that should be rejected
+type constraint struct {
+	name   string // as in the documentation, e.g. minLength
+	param  interface{}
+	edge   string // valuegen edge label; empty if there is no edge to try
+	reject string // errparse rule of the invalid value
+}
+
+// constraintsOf lists the documented bounds and format of a field
+func constraintsOf(info models.FieldInfo) []constraint {
+	var constraints []constraint
+	if info.Minimum != nil {
+		constraints = append(constraints, constraint{"minimum", *info.Minimum, valuegen.EdgeMinimum, errparse.RuleMin})
+	}
+	if info.Maximum != nil {
+		constraints = append(constraints, constraint{"maximum", *info.Maximum, valuegen.EdgeMaximum, errparse.RuleMax})
+	}
+	if info.MinLength != nil {
+		constraints = append(constraints, constraint{"minLength", *info.MinLength, valuegen.EdgeMinLength, errparse.RuleMinLength})
+	}
+	if info.MaxLength != nil {
+		constraints = append(constraints, constraint{"maxLength", *info.MaxLength, valuegen.EdgeMaxLength, errparse.RuleMaxLength})
+	}
+	if info.Format != "" {
+		constraints = append(constraints, constraint{"format", info.Format, "", errparse.RuleFormat})
+	}
+	return constraints
+}
+
+// verifyConstraints checks the documented bounds and formats of the fields
+// of the working body: a value on each bound should be accepted and a value
+// beyond it, or in the wrong format, rejected
+func (a *DeepseekAgent) verifyConstraints() {
+	spec, base := a.spec, a.minimalSuccessBody
+	for _, field := range spec.fieldNames() {
+		info := spec.fields[field]
+		if !containsPath(base, field) {
+			continue
+		}
+		edges := a.values.Edges(info)
+		for _, c := range constraintsOf(info) {
+			claim := fmt.Sprintf("%s: %s %v", field, c.name, c.param)
+			invalid, ok := a.invalidValue(info, c.reject)
+			if !ok {
+				continue
+			}
+			confirmed := true
+			for _, edge := range edges {
+				if c.edge != "" && edge.Rule == c.edge {
+					confirmed = a.checkBoundary(claim, field, c, edge.Value, true)
+				}
+			}
+			if confirmed && a.checkBoundary(claim, field, c, invalid, false) {
+				spec.verify(claim)
+			}
+		}
+	}
+}
+
+// checkBoundary sends a value on or beyond a documented constraint and
+// reports whether the target answered as documented. Drift and unchecked
+// claims are recorded as found.
+func (a *DeepseekAgent) checkBoundary(claim, field string, c constraint, value interface{}, valid bool) bool {
+	spec := a.spec
+	if !a.canProbe() {
+		spec.uncheck(claim, noBudget)
+		return false
+	}
+	resp, err := a.probe(withPath(a.minimalSuccessBody, field, value))
+	if err != nil {
+		spec.uncheck(claim, "probe failed: "+err.Error())
+		return false
+	}
+	documented := map[string]interface{}{c.name: c.param}
+	shown := truncate(toJSON(value), maxSummaryText)
+	if isSuccess(resp) {
+		if valid {
+			return true
+		}
+		spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftConstraintIgnored,
+			Documented: documented, Detail: "the target accepted " + shown})
+		return false
+	}
+	fe, blamed := a.rejectionOf(resp, field)
+	switch {
+	case !blamed:
+		spec.uncheck(claim, "rejected for another reason: "+a.rejectionReason(resp))
+	case !valid:
+		return true
+	default:
+		spec.addDrift(models.DriftItem{Field: field, Kind: models.DriftBoundaryRejected,
+			Documented: documented, Observed: fe.Message, Detail: "the target rejected " + shown})
+	}
+	return false
+}
+
+// invalidValue returns the generated value a field should reject with a rule
+func (a *DeepseekAgent) invalidValue(info models.FieldInfo, rule string) (interface{}, bool) {
+	for _, c := range a.values.Invalid(info) {
+		if c.Rule == rule {
+			return c.Value, true
+		}
+	}
+	return nil, false
+}
+
+// rejectionOf returns the validation error that rejects the value sent for a
+// field, if the target blamed the field for it. Conflicts, rate limits and
+// server errors, and errors about other fields, do not count.
+func (a *DeepseekAgent) rejectionOf(resp *models.HTTPResponse, field string) (errparse.FieldError, bool) {
+	if !isValidationFailure(resp) {
+		return errparse.FieldError{}, false
+	}
+	for _, fe := range a.probeErrors(resp) {
+		if fe.Path == field && fe.Rule != errparse.RuleRequired && fe.Rule != errparse.RuleUnique {
+			return fe, true
+		}
+	}
+	return errparse.FieldError{}, false
+}
+
+// rejectionReason describes a rejection for an unchecked claim
+func (a *DeepseekAgent) rejectionReason(resp *models.HTTPResponse) string {
+	errs := a.probeErrors(resp)
+	if len(errs) == 0 {
+		return fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	return strings.TrimPrefix(strings.ReplaceAll(formatFieldErrors(errs), "\n- ", "; "), "; ")
+}
+
+// verifyAdditionalProperties compares the documented additionalProperties of
+// the body with what the unknown-field probe found
+func (a *DeepseekAgent) verifyAdditionalProperties() {
+	documented, ok := a.spec.schema["additionalProperties"].(bool)
+	observed := a.additionalProperties[""]
+	if !ok || observed == nil {
+		return
+	}
+	if documented == *observed {
+		a.spec.verify(fmt.Sprintf("additionalProperties: %v", documented))
+		return
+	}
+	detail := "the target rejects unknown fields although the documentation allows them"
+	if *observed {
+		detail = "the target accepts unknown fields although the documentation forbids them"
+	}
+	a.spec.addDrift(models.DriftItem{Kind: models.DriftAdditionalProperties,
+		Documented: documented, Observed: *observed, Detail: detail})
+}
+
+// driftReport returns the verification results of the run's OpenAPI
+// operation, adding the undocumented fields the target turned out to accept,
+// or nil if the run had no operation to verify
+func (a *DeepseekAgent) driftReport() *models.DriftReport {
+	spec := a.spec
+	if spec == nil {
+		return nil
+	}
+	report := &models.DriftReport{
+		OperationID: spec.operationID,
+		Items:       append([]models.DriftItem{}, spec.items...),
+		Verified:    append([]string{}, spec.verified...),
+		Unchecked:   append([]string(nil), spec.unchecked...),
+	}
+
+	for _, path := range fieldPaths(a.minimalSuccessBody, "") {
+		parent, _ := splitFieldPath(path)
+		if _, documented := spec.fields[path]; documented || a.confirmedRequired[path] || !spec.documentsObject(parent) {
+			continue
+		}
+		value, _ := valueAt(a.minimalSuccessBody, path)
+		report.Items = append(report.Items, models.DriftItem{Field: path, Kind: models.DriftUndocumentedField,
+			Observed: jsonType(value), Detail: "not documented, but the target accepted it in the working body"})
+	}
+
+	var found []string
+	for name, info := range a.knownFields {
+		if info.Evidence == "" || info.Evidence == models.EvidenceHint || containsPath(a.minimalSuccessBody, name) {
+			continue
+		}
+		parent, _ := splitFieldPath(name)
+		if _, documented := spec.fields[name]; !documented && spec.documentsObject(parent) {
+			found = append(found, name)
+		}
+	}
+	sort.Strings(found)
+	for _, name := range found {
+		info := a.knownFields[name]
+		report.Items = append(report.Items, models.DriftItem{Field: name, Kind: models.DriftUndocumentedField,
+			Observed: info.Type, Detail: fmt.Sprintf("not documented, but found by probing (%s evidence, %s confidence)", info.Evidence, info.Confidence)})
+	}
+	return report
+}
+
+// addDrift records a difference between the documentation and the target
+func (s *specCheck) addDrift(item models.DriftItem) {
+	utils.Logger.Printf("Drift in %s: %s %s", s.operationID, item.Field, item.Kind)
+	s.items = append(s.items, item)
+}
+
+// verify records a claim the target confirmed
+func (s *specCheck) verify(claim string) {
+	if !containsString(s.verified, claim) {
+		s.verified = append(s.verified, claim)
+	}
+}
+
+// uncheck records a claim that could not be tested and why
+func (s *specCheck) uncheck(claim, reason string) {
+	s.unchecked = append(s.unchecked, claim+" ("+reason+")")
+}
+
+// fieldNames lists the documented fields, parents before their children
+func (s *specCheck) fieldNames() []string {
+	names := make([]string, 0, len(s.fields))
+	for name := range s.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// documentsObject reports whether the documentation lists the properties of
+// the object at path, so that other fields found in it are undocumented
+func (s *specCheck) documentsObject(path string) bool {
+	if path == "" {
+		_, hasProperties := s.schema["properties"]
+		return hasProperties
+	}
+	for name := range s.fields {
+		if strings.HasPrefix(name, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldErrorFor returns the first error blaming a field
+func fieldErrorFor(errs []errparse.FieldError, field string) (errparse.FieldError, bool) {
+	for _, fe := range errs {
+		if fe.Path == field {
+			return fe, true
+		}
+	}
+	return errparse.FieldError{}, false
+}
+
+// documentedValue returns a value of a field's documented type, preferring
+// the documented example
+func (a *DeepseekAgent) documentedValue(info models.FieldInfo) interface{} {
+	if info.SampleValue != nil && typeMatches(info.Type, info.SampleValue) {
+		return info.SampleValue
+	}
+	return a.values.Valid(info)
+}
+
+// typeMatches reports whether a value has a documented JSON Schema type.
+// Unknown types match anything.
+func typeMatches(fieldType string, value interface{}) bool {
+	documented, actual := baseType(fieldType), jsonType(value)
+	switch documented {
+	case "string", "integer", "number", "boolean", "array", "object":
+		return documented == actual || (documented == "number" && actual == "integer")
+	default:
+		return true
+	}
+}
+
+// baseType strips the item type from types such as array<string>
+func baseType(fieldType string) string {
+	base, _, _ := strings.Cut(fieldType, "<")
+	return base
+}
+
+// jsonType returns the JSON Schema type of a value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64:
+		return "integer"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// valueAt returns the value at a dotted path of a body
+func valueAt(body map[string]interface{}, path string) (interface{}, bool) {
+	parent, name := splitFieldPath(path)
+	value, present := nestedObject(body, parent)[name]
+	return value, present
+}
+
+// withPath returns a deep copy of body with the dotted path set to value,
+// creating missing parent objects
+func withPath(body map[string]interface{}, path string, value interface{}) map[string]interface{} {
+	clone := deepCopyBody(body)
+	setPath(clone, path, value)
+	return clone
+}
+
+// setPath sets the dotted path of body to value in place, creating missing
+// parent objects. It reports false if a parent is not an object.
+func setPath(body map[string]interface{}, path string, value interface{}) bool {
+	parent, name := splitFieldPath(path)
+	object := body
+	if parent != "" {
+		for _, key := range strings.Split(parent, ".") {
+			next, exists := object[key]
+			if !exists {
+				next = map[string]interface{}{}
+				object[key] = next
+			}
+			nested, ok := next.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			object = nested
+		}
+	}
+	object[name] = value
+	return true
+}
+
+// fieldPaths lists the dotted paths of every field of body, parents before children
+func fieldPaths(body map[string]interface{}, prefix string) []string {
+	var paths []string
+	for _, name := range sortedKeys(body) {
+		path := joinFieldPath(prefix, name)
+		paths = append(paths, path)
+		if nested, ok := body[name].(map[string]interface{}); ok {
+			paths = append(paths, fieldPaths(nested, path)...)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/models"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// usersSchema documents the body of a user signup, with numbers as decoded
+// from JSON
+var usersSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []interface{}{"name", "email", "nickname", "team"},
+	"properties": map[string]interface{}{
+		"name":     map[string]interface{}{"type": "string", "minLength": 3.0, "maxLength": 10.0},
+		"email":    map[string]interface{}{"type": "string", "format": "email"},
+		"nickname": map[string]interface{}{"type": "string"},
+		"team":     map[string]interface{}{"type": "string"},
+		"plan":     map[string]interface{}{"type": "string", "enum": []interface{}{"free", "pro", "enterprise"}},
+		"age":      map[string]interface{}{"type": "integer", "minimum": 18.0},
+	},
+}
+
+// usersTarget validates a signup the way the service really does
+func usersTarget(body map[string]interface{}) (int, interface{}) {
+	for _, field := range []string{"name", "email", "phone"} {
+		if _, ok := body[field]; !ok {
+			return invalid(field, "This field is required.")
+		}
+	}
+	if _, ok := body["team"]; !ok {
+		// A bug in the team lookup, not a requirement
+		return unavailable()
+	}
+	if name, _ := body["name"].(string); len(name) < 3 {
+		return invalid("name", "Ensure this field has at least 3 characters.")
+	}
+	if email, _ := body["email"].(string); !strings.Contains(email, "@") {
+		return invalid("email", "Enter a valid email address.")
+	}
+	if plan, ok := body["plan"]; ok && plan != "free" && plan != "pro" {
+		return invalid("plan", fmt.Sprintf("%q is not a valid choice.", plan))
+	}
+	if age, ok := body["age"].(float64); ok && age < 18 {
+		return unavailable()
+	}
+	return created(body)
+}
+
+// newSpecAgent returns an agent verifying usersSchema against usersTarget
+func newSpecAgent(t *testing.T) *DeepseekAgent {
+	a := newTestAgent(t, usersTarget, map[string]interface{}{
+		"age": 30.0, "email": "ada@example.com", "name": "Ada", "nickname": "ada",
+		"phone": "555-0100", "plan": "free", "team": "core",
+	})
+	fields, required := fieldsFromSchema("", usersSchema)
+	a.spec = &specCheck{operationID: "createUser", schema: usersSchema, fields: make(map[string]models.FieldInfo), required: required}
+	for _, info := range fields {
+		a.spec.fields[info.Name] = info
+	}
+	return a
+}
+
+// driftKinds lists drift items as "field kind"
+func driftKinds(items []models.DriftItem) []string {
+	var kinds []string
+	for _, item := range items {
+		kinds = append(kinds, item.Field+" "+item.Kind)
+	}
+	return kinds
+}
+
+func TestVerifySpec(t *testing.T) {
+	tests := []struct {
+		name      string
+		verify    func(a *DeepseekAgent)
+		drift     []string
+		verified  []string
+		unchecked []string
+	}{
+		{
+			name:      "required",
+			verify:    (*DeepseekAgent).verifyRequired,
+			drift:     []string{"nickname required_not_enforced", "phone undocumented_required"},
+			verified:  []string{"name: required", "email: required"},
+			unchecked: []string{`team: required (rejected for another reason: status 503)`},
+		},
+		{
+			name:     "enums",
+			verify:   func(a *DeepseekAgent) { a.verifyEnums(nil) },
+			drift:    []string{"plan enum_value_rejected"},
+			verified: []string{"plan: enum enforced"},
+		},
+		{
+			name:      "constraints",
+			verify:    (*DeepseekAgent).verifyConstraints,
+			drift:     []string{"name constraint_ignored"},
+			verified:  []string{"email: format email", "name: minLength 3"},
+			unchecked: []string{"age: minimum 18 (rejected for another reason: status 503)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newSpecAgent(t)
+			tt.verify(a)
+			if got := driftKinds(a.spec.items); !reflect.DeepEqual(got, tt.drift) {
+				t.Errorf("drift = %v, want %v", got, tt.drift)
+			}
+			if !reflect.DeepEqual(a.spec.verified, tt.verified) {
+				t.Errorf("verified = %q, want %q", a.spec.verified, tt.verified)
+			}
+			if !reflect.DeepEqual(a.spec.unchecked, tt.unchecked) {
+				t.Errorf("unchecked = %q, want %q", a.spec.unchecked, tt.unchecked)
+			}
+		})
+	}
+}
+
+func TestVerifySpecWithoutBudget(t *testing.T) {
+	a := newSpecAgent(t)
+	a.request.MaxProbes = 0
+	a.verifyRequired()
+	for _, claim := range []string{"name: required", "email: required", "nickname: required", "team: required"} {
+		if want := claim + " (" + noBudget + ")"; !containsString(a.spec.unchecked, want) {
+			t.Errorf("unchecked = %q, want %q in it", a.spec.unchecked, want)
+		}
+	}
+	if len(a.spec.items) != 0 {
+		t.Errorf("drift without probing: %v", driftKinds(a.spec.items))
+	}
+}
+
+func TestDriftReport(t *testing.T) {
+	a := newSpecAgent(t)
+	a.verifySpec()
+	a.knownFields["referral"] = &models.FieldInfo{Name: "referral", Type: "string", Evidence: models.EvidenceEcho, Confidence: "high"}
+	a.knownFields["locale"] = &models.FieldInfo{Name: "locale", Type: "string", Evidence: models.EvidenceHint}
+
+	report := a.driftReport()
+	want := []string{
+		"nickname required_not_enforced",
+		"phone undocumented_required",
+		"plan enum_value_rejected",
+		"name constraint_ignored",
+		"referral undocumented_field",
+	}
+	if report.OperationID != "createUser" || !reflect.DeepEqual(driftKinds(report.Items), want) {
+		t.Errorf("report %s items = %v, want %v", report.OperationID, driftKinds(report.Items), want)
+	}
+	for _, claim := range []string{"name: required", "plan: enum enforced", "age: integer"} {
+		if !containsString(report.Verified, claim) {
+			t.Errorf("verified = %q, want %q in it", report.Verified, claim)
+		}
+	}
+	if len(report.Unchecked) != 2 {
+		t.Errorf("unchecked = %q", report.Unchecked)
+	}
+
+	// Without an operation there is nothing to report
+	a.spec = nil
+	if report := a.driftReport(); report != nil {
+		t.Errorf("report without an operation = %+v", report)
+	}
+}
@@ -0,0 +1,374 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// hiddenFieldBatchSize is how many candidate names are sent in one probe
+const hiddenFieldBatchSize = 6
+
+// builtinCandidates are field names commonly accepted by create endpoints,
+// as "name" or "name:type"
+var builtinCandidates = []string{
+	"name", "firstName", "lastName", "username", "nickname", "title",
+	"description", "notes", "bio", "age:integer", "balance:number",
+	"amount:number", "price:number", "quantity:integer", "currency",
+	"phone", "address", "city", "country", "postalCode", "website:url",
+	"avatar:url", "birthDate:date", "gender", "status", "role", "category",
+	"locale", "timezone", "tags:array<string>", "hobbies:array<string>",
+	"metadata:object", "isActive:boolean", "enabled:boolean", "verified:boolean",
+}
+
+// sharedCandidates are extra candidate names loaded from a wordlist file and
+// tried in every discovery
+var sharedCandidates []string
+
+// LoadWordlist reads extra candidate field names, one "name" or "name:type"
+// per line. Blank lines and lines starting with # are ignored.
+func LoadWordlist(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open wordlist: %w", err)
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read wordlist: %w", err)
+	}
+	sharedCandidates = names
+	return nil
+}
+
+// fieldCandidate is a candidate field name and the value it is probed with
+type fieldCandidate struct {
+	name      string
+	fieldType string // type from the wordlist, if given
+	value     interface{}
+	fixed     bool // value was already corrected once after a validation error
+}
+
+// evidenceConfidence maps how a probed field was confirmed to a confidence level
+var evidenceConfidence = map[string]string{
+	models.EvidenceEcho:       models.ConfidenceHigh,
+	models.EvidenceValidation: models.ConfidenceHigh,
+	models.EvidenceStrict:     models.ConfidenceMedium,
+	models.EvidenceBehaviour:  models.ConfidenceLow,
+}
+
+var confidenceRank = map[string]int{
+	models.ConfidenceLow:    1,
+	models.ConfidenceMedium: 2,
+	models.ConfidenceHigh:   3,
+}
+
+// discoverHiddenFields looks for optional fields the target never asks for.
+// Candidate names from the response, the request, a loaded wordlist and the
+// built-in wordlist are added to the working body in batches, at the top level
+// and in every nested object. A candidate counts as real if the target echoes
+// its value, validates it, accepts it while rejecting unknown fields, or
+// answers differently because of it.
+func (a *DeepseekAgent) discoverHiddenFields() {
+	base := a.minimalSuccessBody
+	if len(base) == 0 || !a.canProbe() {
+		return
+	}
+
+	baseline, err := a.probe(base)
+	if err != nil || !isSuccess(baseline) {
+		return
+	}
+
+	found := 0
+	for _, path := range objectPaths(base, "") {
+		pending := a.hiddenFieldCandidates(path, baseline)
+		for len(pending) > 0 && a.canProbe() {
+			n := hiddenFieldBatchSize
+			if n > len(pending) {
+				n = len(pending)
+			}
+			found += a.probeHiddenBatch(path, pending[:n], baseline)
+			pending = pending[n:]
+		}
+	}
+	utils.Logger.Printf("Found %d optional fields by probing candidate names", found)
+}
+
+// hiddenFieldCandidates lists untried names for the object at path: names the
+// target reported in its response first, then request, wordlist and built-in names
+func (a *DeepseekAgent) hiddenFieldCandidates(path string, baseline *models.HTTPResponse) []*fieldCandidate {
+	object := nestedObject(a.minimalSuccessBody, path)
+	seen := map[string]bool{}
+	var candidates []*fieldCandidate
+
+	// Ids, timestamps and status flags are usually the target's own, so only
+	// wordlist entries, which are listed on purpose, may have such names
+	listed := false
+	add := func(name, fieldType string, value interface{}) {
+		if name == "" || seen[name] || (!listed && isServerGeneratedField(name)) || a.forbidden(joinFieldPath(path, name)) {
+			return
+		}
+		seen[name] = true
+		if _, inBody := object[name]; inBody {
+			return
+		}
+		// Fields only known from hints are still worth confirming
+		if info, known := a.knownFields[joinFieldPath(path, name)]; known && info.Evidence != models.EvidenceHint {
+			return
+		}
+		candidates = append(candidates, &fieldCandidate{name: name, fieldType: fieldType, value: value})
+	}
+
+	// Hinted fields first, with their documented sample values
+	for _, name := range a.hintedFieldNames(path) {
+		info := a.knownFields[joinFieldPath(path, name)]
+		value := info.SampleValue
+		if value == nil {
+			value = a.values.Valid(*info)
+		}
+		add(name, info.Type, value)
+	}
+
+	// Then fields earlier runs saw on the host's other endpoints
+	for _, name := range a.rememberedFieldNames(path) {
+		fullPath := joinFieldPath(path, name)
+		value, remembered := a.rememberedValue(fullPath)
+		if !remembered {
+			value = a.placeholder(name, a.hostKnowledge.Fields[fullPath].Type)
+		}
+		add(name, a.hostKnowledge.Fields[fullPath].Type, value)
+	}
+
+	if respObj := nestedObject(responseObject(baseline), path); respObj != nil {
+		for _, name := range sortedKeys(respObj) {
+			// Send something other than what the target reports on its own,
+			// so an echo cannot be a server default
+			add(name, "", distinctValue(respObj[name]))
+		}
+	}
+
+	listed = true
+	entries := append(append(append([]string(nil), a.request.CandidateFields...), sharedCandidates...), builtinCandidates...)
+	for _, entry := range entries {
+		name, fieldType, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			if name[:dot] != path {
+				continue
+			}
+			name = name[dot+1:]
+		}
+		add(name, fieldType, a.placeholder(name, fieldType))
+	}
+	return candidates
+}
+
+// probeHiddenBatch sends a batch of candidates together and returns how many
+// were confirmed. Candidates the target rejects as unknown are dropped and
+// the rest resent; batches that cannot be resolved are retried one by one.
+func (a *DeepseekAgent) probeHiddenBatch(path string, batch []*fieldCandidate, baseline *models.HTTPResponse) int {
+	found := 0
+	batch = append([]*fieldCandidate(nil), batch...)
+
+	for len(batch) > 0 && a.canProbe() {
+		body := deepCopyBody(a.minimalSuccessBody)
+		target := nestedObject(body, path)
+		for _, c := range batch {
+			target[c.name] = c.value
+		}
+		resp, err := a.probe(body)
+		if err != nil {
+			return found
+		}
+
+		if isSuccess(resp) {
+			return found + a.confirmAccepted(path, batch, resp, baseline)
+		}
+
+		progress := false
+		var remaining []*fieldCandidate
+		for _, c := range batch {
+			fe, blamed := candidateError(path, c, a.probeErrors(resp))
+			if !blamed {
+				remaining = append(remaining, c)
+				continue
+			}
+			progress = true
+			if fe.Rule == errparse.RuleUnknown {
+				continue
+			}
+			// The target validated the value, so the field exists
+			found += a.confirmHiddenField(path, c, nil, models.EvidenceValidation)
+			applyConstraint(a.knownFields[joinFieldPath(path, c.name)], fe)
+			if fe.Rule == errparse.RuleType && fe.Param != "" {
+				a.knownFields[joinFieldPath(path, c.name)].Type = fe.Param
+				if !c.fixed {
+					c.fieldType = fe.Param
+					c.value, c.fixed = a.placeholder(c.name, fe.Param), true
+					remaining = append(remaining, c)
+				}
+			}
+		}
+		batch = remaining
+
+		if !progress {
+			if len(batch) > 1 {
+				for _, c := range batch {
+					found += a.probeHiddenBatch(path, []*fieldCandidate{c}, baseline)
+				}
+			}
+			return found
+		}
+	}
+	return found
+}
+
+// confirmAccepted records the candidates of an accepted batch that the
+// response shows to be real
+func (a *DeepseekAgent) confirmAccepted(path string, batch []*fieldCandidate, resp, baseline *models.HTTPResponse) int {
+	found := 0
+	respObj := nestedObject(responseObject(resp), path)
+	baseObj := nestedObject(responseObject(baseline), path)
+
+	var silent []*fieldCandidate
+	for _, c := range batch {
+		if echoesValue(respObj, c.name, c.value) && !echoesValue(baseObj, c.name, c.value) {
+			found += a.confirmHiddenField(path, c, c.value, models.EvidenceEcho)
+		} else {
+			silent = append(silent, c)
+		}
+	}
+	if len(silent) == 0 {
+		return found
+	}
+
+	if accepted, tested := a.additionalProperties[path]; tested && !*accepted {
+		for _, c := range silent {
+			found += a.confirmHiddenField(path, c, c.value, models.EvidenceStrict)
+		}
+		return found
+	}
+
+	names := make([]string, len(silent))
+	for i, c := range silent {
+		names[i] = c.name
+	}
+	if responseSignature(resp, names) == responseSignature(baseline, names) {
+		return found
+	}
+	// The response changed, but only a single field can be blamed for it
+	if len(silent) == 1 {
+		return found + a.confirmHiddenField(path, silent[0], silent[0].value, models.EvidenceBehaviour)
+	}
+	for _, c := range silent {
+		if !a.canProbe() {
+			break
+		}
+		found += a.probeHiddenBatch(path, []*fieldCandidate{c}, baseline)
+	}
+	return found
+}
+
+// confirmHiddenField records a probed field as optional, keeping the highest
+// confidence seen for it, and returns 1 if the field is new
+func (a *DeepseekAgent) confirmHiddenField(path string, c *fieldCandidate, value interface{}, evidence string) int {
+	fullPath := joinFieldPath(path, c.name)
+	confidence := evidenceConfidence[evidence]
+
+	info, exists := a.knownFields[fullPath]
+	if !exists {
+		info = &models.FieldInfo{Name: fullPath, Type: c.fieldType}
+		if info.Type == "" {
+			info.Type = inferType(c.value)
+		}
+		a.knownFields[fullPath] = info
+		a.fieldStatus[fullPath] = &FieldTestStatus{IsDiscovered: true}
+	}
+	if value != nil {
+		info.SampleValue = value
+		a.fieldStatus[fullPath].IsTypeVerified = true
+		a.fieldStatus[fullPath].SuccessfulTests++
+	}
+	if confidenceRank[confidence] > confidenceRank[info.Confidence] {
+		info.Confidence = confidence
+		info.Evidence = evidence
+		utils.Logger.Printf("Optional field '%s' found (%s, confidence %s)", fullPath, evidence, confidence)
+	}
+	if exists {
+		return 0
+	}
+	return 1
+}
+
+// candidateError returns the field error a response reports for a candidate
+func candidateError(path string, c *fieldCandidate, errs []errparse.FieldError) (errparse.FieldError, bool) {
+	fullPath := joinFieldPath(path, c.name)
+	for _, fe := range errs {
+		if fe.Path == fullPath || fe.Path == c.name {
+			return fe, true
+		}
+	}
+	return errparse.FieldError{}, false
+}
+
+// echoesValue reports whether obj holds value under name, comparing by JSON
+// encoding so that 1 and 1.0 are equal
+func echoesValue(obj map[string]interface{}, name string, value interface{}) bool {
+	got, ok := obj[name]
+	if !ok {
+		return false
+	}
+	gotJSON, err1 := json.Marshal(got)
+	wantJSON, err2 := json.Marshal(value)
+	return err1 == nil && err2 == nil && string(gotJSON) == string(wantJSON)
+}
+
+// responseSignature summarises a response as its status and top-level keys,
+// leaving out the given names so that echoing them does not count as a change
+func responseSignature(resp *models.HTTPResponse, ignore []string) string {
+	var keys []string
+	for name := range responseObject(resp) {
+		if !containsString(ignore, name) {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("%d %v", resp.StatusCode, keys)
+}
+
+// distinctValue returns a value of the same kind that differs from value
+func distinctValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bool:
+		return !v
+	case float64:
+		return v + 1
+	case string:
+		return v + "-probe"
+	default:
+		return value
+	}
+}
+
+// joinFieldPath joins an object path and a field name with a dot
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,295 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// seedFromHints records what the request's hints say about the endpoint.
+// Hinted fields are known from the start but stay unconfirmed until the
+// target accepts them; required fields from a schema are only passed on to
+// the LLM, since the target decides what is really required.
+func (a *DeepseekAgent) seedFromHints() {
+	hints := a.request.Hints
+	seeded := len(a.knownFields)
+	if hints.Schema != nil {
+		fields, required := fieldsFromSchema("", hints.Schema)
+		for _, info := range fields {
+			a.seedField(info)
+		}
+		a.hintedRequired = append(a.hintedRequired, required...)
+	}
+	for _, example := range hints.Examples {
+		for _, name := range sortedKeys(example) {
+			a.seedField(models.FieldInfo{Name: name, Type: inferType(example[name]), SampleValue: example[name]})
+		}
+	}
+	for _, entry := range hints.KnownFields {
+		name, fieldType, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		a.seedField(models.FieldInfo{Name: name, Type: fieldType})
+	}
+	if len(a.knownFields) > seeded {
+		utils.Logger.Printf("Seeded %d fields from hints", len(a.knownFields)-seeded)
+	}
+	a.priorFields = a.describePriorFields()
+}
+
+// seedField adds a hinted field, or fills in what an earlier hint left out
+func (a *DeepseekAgent) seedField(info models.FieldInfo) {
+	if info.Name == "" || a.forbidden(info.Name) {
+		return
+	}
+	existing, exists := a.knownFields[info.Name]
+	if !exists {
+		info.Evidence = models.EvidenceHint
+		a.knownFields[info.Name] = &info
+		a.fieldStatus[info.Name] = &FieldTestStatus{IsDiscovered: true}
+		return
+	}
+	if existing.Type == "" {
+		existing.Type = info.Type
+	}
+	if existing.SampleValue == nil {
+		existing.SampleValue = info.SampleValue
+	}
+}
+
+// confirmHint records that the target itself has shown a hinted field exists
+func (a *DeepseekAgent) confirmHint(name string) {
+	if info, exists := a.knownFields[name]; exists && info.Evidence == models.EvidenceHint {
+		info.Evidence = ""
+	}
+}
+
+// describePriorFields describes the fields known before the run, for the prompt
+func (a *DeepseekAgent) describePriorFields() []string {
+	var fields []string
+	for name, info := range a.knownFields {
+		if info.Evidence != models.EvidenceHint {
+			continue
+		}
+		field := name
+		if info.Type != "" && info.Format != "" {
+			field += " (" + info.Type + ", " + info.Format + ")"
+		} else if info.Type != "" {
+			field += " (" + info.Type + ")"
+		}
+		if info.SampleValue != nil {
+			field += " e.g. " + toJSON(info.SampleValue)
+		}
+		fields = append(fields, field)
+	}
+	for _, name := range sortedKeys(a.request.InitialBody) {
+		if _, hinted := a.knownFields[name]; !hinted {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// hintedFieldNames returns the names of unconfirmed hinted fields directly
+// inside the object at path
+func (a *DeepseekAgent) hintedFieldNames(path string) []string {
+	var names []string
+	for fullPath, info := range a.knownFields {
+		if info.Evidence != models.EvidenceHint {
+			continue
+		}
+		if parent, name := splitFieldPath(fullPath); parent == path {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fieldsFromSchema reads the properties of a JSON Schema or OpenAPI schema
+// object. Nested object properties are returned under dotted names, and so
+// are the required properties of nested objects, which are only required
+// when their object is sent.
+func fieldsFromSchema(prefix string, schema map[string]interface{}) ([]models.FieldInfo, []string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	var fields []models.FieldInfo
+	var required []string
+	for _, name := range stringList(schema["required"]) {
+		required = append(required, joinFieldPath(prefix, name))
+	}
+	for _, name := range sortedKeys(properties) {
+		prop, _ := properties[name].(map[string]interface{})
+		info := fieldFromSchema(joinFieldPath(prefix, name), prop)
+		fields = append(fields, info)
+		if info.Type == "object" {
+			nested, nestedRequired := fieldsFromSchema(info.Name, prop)
+			fields = append(fields, nested...)
+			required = append(required, nestedRequired...)
+		}
+	}
+	return fields, required
+}
+
+// fieldFromSchema converts one property schema to a field
+func fieldFromSchema(name string, prop map[string]interface{}) models.FieldInfo {
+	info := models.FieldInfo{Name: name}
+	info.Type, info.Nullable = schemaType(prop)
+	if items, ok := prop["items"].(map[string]interface{}); ok && info.Type == "array" {
+		if itemType, _ := schemaType(items); itemType != "" {
+			info.Type = "array<" + itemType + ">"
+		}
+	}
+	info.Format, _ = prop["format"].(string)
+	info.Pattern, _ = prop["pattern"].(string)
+	info.Description, _ = prop["description"].(string)
+	for _, value := range anyList(prop["enum"]) {
+		info.Enum = append(info.Enum, fmt.Sprint(value))
+	}
+	info.MinLength = intValue(prop["minLength"])
+	info.MaxLength = intValue(prop["maxLength"])
+	info.Minimum = floatValue(prop["minimum"])
+	info.Maximum = floatValue(prop["maximum"])
+
+	switch {
+	case prop["example"] != nil:
+		info.SampleValue = prop["example"]
+	case len(anyList(prop["examples"])) > 0:
+		info.SampleValue = anyList(prop["examples"])[0]
+	case prop["default"] != nil:
+		info.SampleValue = prop["default"]
+	}
+	return info
+}
+
+// schemaType returns a schema's type and, if the schema says so, whether
+// null is allowed. Both JSON Schema type lists and OpenAPI's nullable are read.
+func schemaType(schema map[string]interface{}) (string, *bool) {
+	var nullable *bool
+	if n, ok := schema["nullable"].(bool); ok {
+		nullable = &n
+	}
+	switch t := schema["type"].(type) {
+	case string:
+		return t, nullable
+	case []interface{}:
+		fieldType := ""
+		for _, entry := range t {
+			if entry == "null" {
+				allowed := true
+				nullable = &allowed
+			} else if s, ok := entry.(string); ok && fieldType == "" {
+				fieldType = s
+			}
+		}
+		return fieldType, nullable
+	}
+	if _, hasProperties := schema["properties"]; hasProperties {
+		return "object", nullable
+	}
+	return "", nullable
+}
+
+// forbidden reports whether the hints say a field must never be sent
+func (a *DeepseekAgent) forbidden(path string) bool {
+	for _, name := range a.request.Hints.DoNotSend {
+		if name == path {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutForbidden returns the body with every doNotSend field removed, and
+// the fields it removed. The body is copied only when something is removed.
+func (a *DeepseekAgent) withoutForbidden(body interface{}) (interface{}, []string) {
+	var removed []string
+	for _, path := range a.request.Hints.DoNotSend {
+		if containsPath(body, path) {
+			removed = append(removed, path)
+		}
+	}
+	if len(removed) == 0 {
+		return body, nil
+	}
+
+	body = deepCopyValue(body)
+	for _, path := range removed {
+		deletePath(body, path)
+	}
+	return body, removed
+}
+
+// containsPath reports whether a body, or any item of an array body, holds a dotted path
+func containsPath(body interface{}, path string) bool {
+	switch b := body.(type) {
+	case map[string]interface{}:
+		parent, name := splitFieldPath(path)
+		_, present := nestedObject(b, parent)[name]
+		return present
+	case []interface{}:
+		for _, item := range b {
+			if containsPath(item, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deletePath removes a dotted path from a body, or from every item of an array body
+func deletePath(body interface{}, path string) {
+	switch b := body.(type) {
+	case map[string]interface{}:
+		parent, name := splitFieldPath(path)
+		if object := nestedObject(b, parent); object != nil {
+			delete(object, name)
+		}
+	case []interface{}:
+		for _, item := range b {
+			deletePath(item, path)
+		}
+	}
+}
+
+// splitFieldPath splits a dotted path into its parent path and last name
+func splitFieldPath(path string) (string, string) {
+	if dot := strings.LastIndex(path, "."); dot >= 0 {
+		return path[:dot], path[dot+1:]
+	}
+	return "", path
+}
+
+// stringList reads a JSON array of strings
+func stringList(value interface{}) []string {
+	var list []string
+	for _, entry := range anyList(value) {
+		if s, ok := entry.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// anyList reads a JSON array
+func anyList(value interface{}) []interface{} {
+	list, _ := value.([]interface{})
+	return list
+}
+
+// intValue reads a JSON number as an int
+func intValue(value interface{}) *int {
+	if n, ok := value.(float64); ok {
+		i := int(n)
+		return &i
+	}
+	return nil
+}
+
+// floatValue reads a JSON number
+func floatValue(value interface{}) *float64 {
+	if n, ok := value.(float64); ok {
+		return &n
+	}
+	return nil
+}
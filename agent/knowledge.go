@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/knowledge"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxKnowledgeFields caps the remembered fields described to the LLM
+const maxKnowledgeFields = 40
+
+// secretFieldRegex matches body fields whose values are never stored
+var secretFieldRegex = regexp.MustCompile(`(?i)passw(or)?d|secret|token|api[-_]?key|credential`)
+
+// seedFromKnowledge starts the run from what earlier runs learned about the
+// target host: the working body of the same endpoint, headers they had to
+// add, the error dialect the host speaks and the fields seen on its other
+// endpoints. Credentials are never stored, so missing ones are only pointed out.
+func (a *DeepseekAgent) seedFromKnowledge() {
+	if !knowledge.Enabled() || a.request.IgnoreKnowledge {
+		return
+	}
+	h, err := knowledge.Get(knowledge.HostOf(a.request.URL))
+	if err != nil {
+		utils.Logger.Printf("Not using the knowledge base: %v", err)
+		return
+	}
+	if h == nil {
+		return
+	}
+	a.hostKnowledge = h
+	utils.Logger.Printf("Seeding from %d earlier runs on %s", h.Runs, h.Host)
+
+	endpoint := knowledge.EndpointOf(a.request.Method, a.request.URL)
+	if known, ok := h.Endpoints[endpoint]; ok && len(a.currentBody) == 0 {
+		a.currentBody = deepCopyBody(known.MinimalBody)
+		utils.Logger.Printf("Starting from the working body of an earlier run of %s", endpoint)
+	}
+	// Unique fields need new values, or the first request fails as a duplicate
+	for name := range a.currentBody {
+		if field, ok := h.Fields[name]; ok && field.Unique {
+			a.markFieldKnown(name)
+			a.knownFields[name].Unique = true
+		}
+	}
+	a.currentBody = a.freshenBody(a.currentBody)
+	for name, value := range h.Headers {
+		if !hasHeader(a.headers, name) {
+			a.headers[name] = value
+		}
+	}
+	if name := h.Dialect(); name != "" {
+		for _, d := range errparse.Dialects() {
+			if d.Name == name {
+				a.errorRules = append(a.errorRules, d)
+			}
+		}
+	}
+	a.knowledgeNotes = a.describeKnowledge(endpoint)
+}
+
+// describeKnowledge describes the host's knowledge base entry for the prompt
+func (a *DeepseekAgent) describeKnowledge(endpoint string) []string {
+	h := a.hostKnowledge
+	var notes []string
+	for _, name := range h.Auth.Headers {
+		if !hasHeader(a.headers, name) {
+			notes = append(notes, fmt.Sprintf("Earlier runs sent credentials in the %s header, which this run does not have.", name))
+		}
+	}
+	if h.Auth.Challenged && len(h.Auth.Headers) == 0 {
+		notes = append(notes, "The host has answered 401 or 403 before; it may need credentials.")
+	}
+	if _, ok := h.Endpoints[endpoint]; ok && len(a.request.InitialBody) == 0 {
+		notes = append(notes, "The current body is the working body of an earlier run of this endpoint, without any secret fields such as passwords; check it still works.")
+	}
+
+	names := make([]string, 0, len(h.Fields))
+	for name := range h.Fields {
+		if !a.forbidden(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > maxKnowledgeFields {
+		names = names[:maxKnowledgeFields]
+	}
+	for _, name := range names {
+		field := h.Fields[name]
+		line := name
+		if field.Type != "" {
+			line += " (" + field.Type + ")"
+		}
+		if value, ok := a.rememberedValue(name); ok {
+			line += " e.g. " + toJSON(value)
+		}
+		notes = append(notes, line+" - seen on "+strings.Join(field.Endpoints, ", "))
+	}
+	return notes
+}
+
+// rememberedValue returns a value the target host accepted for a field in an
+// earlier run. Values of unique fields are not reused.
+func (a *DeepseekAgent) rememberedValue(name string) (interface{}, bool) {
+	if a.hostKnowledge == nil {
+		return nil, false
+	}
+	field, known := a.hostKnowledge.Fields[name]
+	if !known || field.Unique || len(field.Values) == 0 {
+		return nil, false
+	}
+	return deepCopyValue(field.Values[0]), true
+}
+
+// rememberedFieldNames returns the names of fields seen on the host that would
+// sit directly inside the object at path
+func (a *DeepseekAgent) rememberedFieldNames(path string) []string {
+	if a.hostKnowledge == nil {
+		return nil
+	}
+	var names []string
+	for fullPath := range a.hostKnowledge.Fields {
+		if parent, name := splitFieldPath(fullPath); parent == path {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// remember records what a run learned in the knowledge base. Of a failed
+// run, given as a nil schema, only credentials and dialects are recorded.
+func (a *DeepseekAgent) remember(schema *models.DiscoveredSchema) {
+	if !knowledge.Enabled() {
+		return
+	}
+	run := knowledge.Run{
+		Failed:   schema == nil,
+		Endpoint: knowledge.EndpointOf(a.request.Method, a.request.URL),
+		Headers:  make(map[string]string),
+		Dialects: a.dialects,
+	}
+	for name, value := range a.headers {
+		if secretHeaderRegex.MatchString(name) {
+			run.AuthHeaders = append(run.AuthHeaders, name)
+		} else if !hasHeader(a.request.Headers, name) && !run.Failed {
+			run.Headers[name] = value
+		}
+	}
+	for _, exchange := range a.target.Record().Exchanges {
+		if exchange.StatusCode == http.StatusUnauthorized || exchange.StatusCode == http.StatusForbidden {
+			run.Challenged = true
+		}
+	}
+	if run.Failed && !run.Challenged && len(run.AuthHeaders) == 0 && len(run.Dialects) == 0 {
+		return
+	}
+
+	if schema != nil {
+		run.MinimalBody = storableBody(a.freshenBody(schema.MinimalRequestBody))
+		for _, info := range schema.Fields {
+			if info.Evidence == models.EvidenceHint {
+				continue
+			}
+			// Keep only values the target accepted in a request, and no secrets
+			sent := containsPath(schema.MinimalRequestBody, info.Name)
+			if (!sent && info.Evidence == "") || info.Unique || isSecretField(info.Name) {
+				info.SampleValue = nil
+			}
+			run.Fields = append(run.Fields, info)
+		}
+	}
+
+	host := knowledge.HostOf(a.request.URL)
+	if err := knowledge.Record(host, run); err != nil {
+		utils.Logger.Printf("Failed to update the knowledge base: %v", err)
+		return
+	}
+	if run.Failed {
+		utils.Logger.Printf("Recorded the credentials and error dialects of a failed run of %s in the knowledge base for %s", run.Endpoint, host)
+		return
+	}
+	utils.Logger.Printf("Recorded %d fields of %s in the knowledge base for %s", len(run.Fields), run.Endpoint, host)
+}
+
+// storableBody returns a copy of a working body without secret-looking fields,
+// such as passwords and tokens, at any depth
+func storableBody(body map[string]interface{}) map[string]interface{} {
+	stored := deepCopyBody(body)
+	for _, path := range fieldPaths(body, "") {
+		if isSecretField(path) {
+			deletePath(stored, path)
+		}
+	}
+	return stored
+}
+
+// isSecretField reports whether a field's name, the last part of its dotted
+// path, looks like it holds a credential
+func isSecretField(path string) bool {
+	_, name := splitFieldPath(path)
+	return secretFieldRegex.MatchString(name)
+}
+
+// countDialect counts an error response parsed by a dialect, for the knowledge base
+func (a *DeepseekAgent) countDialect(result *errparse.Result) {
+	if result != nil && len(result.Errors) > 0 {
+		a.dialects[result.Dialect]++
+	}
+}
+
+// hasHeader reports whether headers set a header, whatever its case
+func hasHeader(headers map[string]string, name string) bool {
+	for existing := range headers {
+		if strings.EqualFold(existing, name) {
+			return true
+		}
+	}
+	return false
+}
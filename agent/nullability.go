@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"encoding/json"
+	"sort"
+)
+
+// probeEmptyValues classifies how the target treats null, empty and omitted
+// values. Every field of the minimal body and every accepted optional field is
+// sent once as null and once as its type's empty value; the response to the
+// minimal body shows what the target fills in for omitted optional fields.
+func (a *DeepseekAgent) probeEmptyValues() {
+	base := a.minimalSuccessBody
+	if len(base) == 0 || !a.canProbe() {
+		return
+	}
+
+	// Defaults the target reports for fields that were left out
+	resp, err := a.probe(base)
+	if err == nil && isSuccess(resp) {
+		if respObj := responseObject(resp); respObj != nil {
+			for name, info := range a.knownFields {
+				if containsPath(base, name) {
+					continue
+				}
+				if value, reported := valueAt(respObj, name); reported && !isServerGeneratedField(name) {
+					info.DefaultOnOmission = value
+				}
+			}
+		}
+	}
+
+	for _, field := range a.emptyValueCandidates() {
+		info := a.knownFields[field]
+		value, inBase := base[field]
+		if !inBase {
+			value = a.sampleValue(field)
+		}
+
+		if a.canProbe() {
+			if resp, err := a.probe(withField(base, field, nil)); err == nil {
+				info.Nullable = a.emptyAccepted(resp, field)
+			}
+		}
+
+		empty, ok := emptyValue(value)
+		if ok && a.canProbe() {
+			if resp, err := a.probe(withField(base, field, empty)); err == nil {
+				info.AllowEmpty = a.emptyAccepted(resp, field)
+			}
+		}
+		utils.Logger.Printf("Field '%s': nullable=%v allowEmpty=%v", field, derefBool(info.Nullable), derefBool(info.AllowEmpty))
+	}
+}
+
+// emptyAccepted tells from the response to a null or empty value whether the
+// target accepts it for field. Only a rejection that blames the field counts
+// against it, a "required" error included since that is how many targets
+// refuse empty values; anything else leaves the answer unknown.
+func (a *DeepseekAgent) emptyAccepted(resp *models.HTTPResponse, field string) *bool {
+	if isSuccess(resp) {
+		return boolPtr(true)
+	}
+	if _, blamed := a.rejectionOf(resp, field); blamed {
+		return boolPtr(false)
+	}
+	if isValidationFailure(resp) {
+		for _, fe := range a.probeErrors(resp) {
+			if fe.Path == field && fe.Rule == errparse.RuleRequired {
+				return boolPtr(false)
+			}
+		}
+	}
+	return nil
+}
+
+// emptyValueCandidates lists the fields of the minimal body followed by
+// optional fields that the target has accepted
+func (a *DeepseekAgent) emptyValueCandidates() []string {
+	fields := sortedKeys(a.minimalSuccessBody)
+	var optional []string
+	for name, status := range a.fieldStatus {
+		if _, inBase := a.minimalSuccessBody[name]; inBase {
+			continue
+		}
+		if _, known := a.knownFields[name]; known && !status.IsInMinimalSet && status.SuccessfulTests > 0 {
+			optional = append(optional, name)
+		}
+	}
+	sort.Strings(optional)
+	return append(fields, optional...)
+}
+
+// emptyValue returns the empty value of the same JSON type as value. Booleans
+// have no meaningful empty value.
+func emptyValue(value interface{}) (interface{}, bool) {
+	switch value.(type) {
+	case string:
+		return "", true
+	case float64, int:
+		return 0, true
+	case []interface{}:
+		return []interface{}{}, true
+	case map[string]interface{}:
+		return map[string]interface{}{}, true
+	default:
+		return nil, false
+	}
+}
+
+// responseObject decodes a response body as an object, using the first item
+// of an array response (as returned by batch endpoints)
+func responseObject(resp *models.HTTPResponse) map[string]interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal(resp.ResponseBody, &decoded); err != nil {
+		return nil
+	}
+	switch v := decoded.(type) {
+	case map[string]interface{}:
+		return v
+	case []interface{}:
+		if len(v) > 0 {
+			if obj, ok := v[0].(map[string]interface{}); ok {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func derefBool(b *bool) interface{} {
+	if b == nil {
+		return "unknown"
+	}
+	return *b
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/models"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestProbeEmptyValues(t *testing.T) {
+	handle := func(body map[string]interface{}) (int, interface{}) {
+		switch body["name"] {
+		case nil:
+			return invalid("name", "This field may not be null.")
+		case "":
+			return invalid("name", "This field may not be blank.")
+		}
+		switch body["bio"] {
+		case nil:
+			return unavailable()
+		case "":
+			// Blames another field, so it says nothing about bio
+			return invalid("name", "Ensure this field has at least 3 characters.")
+		}
+		if body["age"] == 0.0 {
+			return http.StatusTooManyRequests, map[string]string{"detail": "slow down"}
+		}
+		reply := map[string]interface{}{"id": 7, "role": "member", "settings": map[string]interface{}{"theme": "light"}}
+		for name, value := range body {
+			reply[name] = value
+		}
+		return http.StatusCreated, reply
+	}
+	a := newTestAgent(t, handle, map[string]interface{}{"age": 30.0, "bio": "Mathematician", "name": "Ada", "nickname": "ada"})
+	for _, name := range []string{"role", "settings.theme", "id"} {
+		a.knownFields[name] = &models.FieldInfo{Name: name, Type: "string"}
+	}
+	a.probeEmptyValues()
+
+	tests := []struct {
+		field                string
+		nullable, allowEmpty *bool
+	}{
+		{"name", boolPtr(false), boolPtr(false)},
+		{"nickname", boolPtr(true), boolPtr(true)},
+		{"bio", nil, nil},
+		{"age", boolPtr(true), nil},
+	}
+	for _, tt := range tests {
+		info := a.knownFields[tt.field]
+		if !reflect.DeepEqual(info.Nullable, tt.nullable) || !reflect.DeepEqual(info.AllowEmpty, tt.allowEmpty) {
+			t.Errorf("%s nullable=%v allowEmpty=%v, want %v %v", tt.field,
+				derefBool(info.Nullable), derefBool(info.AllowEmpty), derefBool(tt.nullable), derefBool(tt.allowEmpty))
+		}
+	}
+
+	// Defaults are read from the reply, nested ones included, but not for
+	// fields the server generates
+	for field, want := range map[string]interface{}{"role": "member", "settings.theme": "light", "id": nil} {
+		if got := a.knownFields[field].DefaultOnOmission; got != want {
+			t.Errorf("%s default = %v, want %v", field, got, want)
+		}
+	}
+}
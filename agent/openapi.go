@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/openapi"
+	"ai-agent-api-discovery/utils"
+	"encoding/json"
+	"fmt"
+)
+
+// specCheck is an OpenAPI operation the run verifies, and what it found
+type specCheck struct {
+	operationID string
+	schema      map[string]interface{}
+	fields      map[string]models.FieldInfo // documented fields by dotted path
+	required    []string                    // documented required fields, nested ones by dotted path
+	items       []models.DriftItem
+	verified    []string
+	unchecked   []string
+}
+
+// ResolveOpenAPI loads the document a request points at and checks that the
+// operation exists and documents a JSON body. The document is stored back in
+// the request so it is not fetched again, and the request takes the
+// operation's method unless it names one.
+func ResolveOpenAPI(req *models.DiscoverRequest) error {
+	seed := req.OpenAPI
+	if seed == nil {
+		return nil
+	}
+	doc, err := loadDocument(seed)
+	if err != nil {
+		return err
+	}
+	if seed.OperationID == "" {
+		return fmt.Errorf("openapi.operationId is required")
+	}
+	op, err := doc.Operation(seed.OperationID)
+	if err != nil {
+		return err
+	}
+	if op.Schema == nil {
+		return fmt.Errorf("operation %s documents no JSON request body", op.ID)
+	}
+
+	if req.Method == "" {
+		req.Method = op.Method
+	}
+	seed.Spec, seed.SpecURL = doc.Raw(), ""
+	return nil
+}
+
+// loadDocument parses the inline document of a seed, or fetches it
+func loadDocument(seed *models.OpenAPISeed) (*openapi.Document, error) {
+	switch {
+	case seed.Spec != nil && seed.SpecURL != "":
+		return nil, fmt.Errorf("openapi.spec and openapi.specUrl are mutually exclusive")
+	case seed.SpecURL != "":
+		return openapi.Fetch(seed.SpecURL)
+	case seed.Spec == nil:
+		return nil, fmt.Errorf("openapi.spec or openapi.specUrl is required")
+	}
+
+	if text, ok := seed.Spec.(string); ok {
+		return openapi.Parse([]byte(text))
+	}
+	data, err := json.Marshal(seed.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi.spec: %w", err)
+	}
+	return openapi.Parse(data)
+}
+
+// seedFromSpec seeds the run with the documented fields of the request's
+// OpenAPI operation and keeps the documentation to verify it later
+func (a *DeepseekAgent) seedFromSpec() error {
+	if a.request.OpenAPI == nil {
+		return nil
+	}
+	doc, err := loadDocument(a.request.OpenAPI)
+	if err != nil {
+		return err
+	}
+	op, err := doc.Operation(a.request.OpenAPI.OperationID)
+	if err != nil {
+		return err
+	}
+	if op.Schema == nil {
+		return fmt.Errorf("operation %s documents no JSON request body", op.ID)
+	}
+
+	fields, required := fieldsFromSchema("", op.Schema)
+	a.spec = &specCheck{
+		operationID: op.ID,
+		schema:      op.Schema,
+		fields:      make(map[string]models.FieldInfo, len(fields)),
+		required:    required,
+	}
+	for _, info := range fields {
+		a.spec.fields[info.Name] = info
+		a.seedField(info)
+	}
+	a.hintedRequired = append(a.hintedRequired, required...)
+	utils.Logger.Printf("Seeded %d fields from operation %s (%s %s)", len(fields), op.ID, op.Method, op.Path)
+	return nil
+}
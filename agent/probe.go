@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// probe sends a body straight to the target, outside the LLM loop. Probes are
+// used after a working body is found to test specific hypotheses cheaply.
+// Fields known to be unique get fresh values so probes do not fail as duplicates.
+func (a *DeepseekAgent) probe(body map[string]interface{}) (*models.HTTPResponse, error) {
+	return a.probeRaw(a.freshenBody(body))
+}
+
+// probeRaw sends a probe body exactly as given
+func (a *DeepseekAgent) probeRaw(body map[string]interface{}) (*models.HTTPResponse, error) {
+	a.probes++
+	requestBody, _ := a.withoutForbidden(a.shapeBody(body))
+	utils.Logger.Printf("Probe %d/%d with body: %+v", a.probes, a.request.MaxProbes, requestBody)
+	resp, err := a.target.Do(models.PurposeProbe, a.request.Method, a.request.URL, a.headers, requestBody)
+	if err != nil {
+		utils.Logger.Printf("Probe failed: %v", err)
+		return nil, err
+	}
+	utils.Logger.Printf("Probe returned status %d", resp.StatusCode)
+	return resp, nil
+}
+
+// canProbe reports whether the probe budget allows another probe and the
+// target is still answering
+func (a *DeepseekAgent) canProbe() bool {
+	return a.probes < a.request.MaxProbes && !a.target.IsOpen() && a.ctx.Err() == nil
+}
+
+// probeErrors extracts field errors from a probe response without involving the LLM
+func (a *DeepseekAgent) probeErrors(resp *models.HTTPResponse) []errparse.FieldError {
+	contentType := http.Header(resp.Headers).Get("Content-Type")
+	if result := errparse.ParseWithRules(a.errorRules, contentType, resp.ResponseBody); result != nil {
+		a.countDialect(result)
+		return result.Errors
+	}
+	return nil
+}
+
+// shapeBody wraps a body the way the target expects it, e.g. as a one-item
+// array for batch endpoints
+func (a *DeepseekAgent) shapeBody(body map[string]interface{}) interface{} {
+	if isBatchURL(a.request.URL) {
+		return []interface{}{body}
+	}
+	return body
+}
+
+// isBatchURL reports whether a URL looks like a batch/bulk endpoint
+func isBatchURL(url string) bool {
+	url = strings.ToLower(url)
+	return strings.Contains(url, "batch") || strings.Contains(url, "bulk")
+}
+
+// isSuccess reports whether a response has a 2xx status
+func isSuccess(resp *models.HTTPResponse) bool {
+	return resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// registerField makes sure a field from a working body is tracked
+func (a *DeepseekAgent) registerField(name string, value interface{}) {
+	if _, exists := a.knownFields[name]; !exists {
+		a.knownFields[name] = &models.FieldInfo{
+			Name:        name,
+			Type:        inferType(value),
+			SampleValue: value,
+		}
+	}
+	if _, exists := a.fieldStatus[name]; !exists {
+		a.fieldStatus[name] = &FieldTestStatus{
+			IsDiscovered:   true,
+			IsTypeVerified: true,
+		}
+	}
+}
+
+// registerBodyFields makes sure every top-level field of a body is tracked
+func (a *DeepseekAgent) registerBodyFields(body map[string]interface{}) {
+	for name, value := range body {
+		a.registerField(name, value)
+	}
+}
+
+// cloneBody returns a shallow copy of a request body
+func cloneBody(body map[string]interface{}) map[string]interface{} {
+	clone := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		clone[k] = v
+	}
+	return clone
+}
+
+// withField returns a copy of body with field set to value
+func withField(body map[string]interface{}, field string, value interface{}) map[string]interface{} {
+	clone := cloneBody(body)
+	clone[field] = value
+	return clone
+}
+
+// withoutField returns a copy of body without field
+func withoutField(body map[string]interface{}, field string) map[string]interface{} {
+	clone := cloneBody(body)
+	delete(clone, field)
+	return clone
+}
+
+// sortedKeys returns the keys of body in a stable order so probe runs are reproducible
+func sortedKeys(body map[string]interface{}) []string {
+	keys := make([]string, 0, len(body))
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// placeholder returns a plausible value for a field that has no sample yet.
+// fieldType may also be a format such as email or uuid.
+func (a *DeepseekAgent) placeholder(name, fieldType string) interface{} {
+	return a.values.Valid(models.FieldInfo{Name: name, Type: fieldType})
+}
+
+// suggestedValue returns a generated value for a field that has no working
+// value yet, to offer the LLM. It stays the same while the field's type and
+// format do, so the prompt does not change between turns for no reason.
+func (a *DeepseekAgent) suggestedValue(info models.FieldInfo) interface{} {
+	key := info.Name + "|" + info.Type + "|" + info.Format
+	if value, exists := a.suggestions[key]; exists {
+		return value
+	}
+	value, remembered := a.rememberedValue(info.Name)
+	if !remembered {
+		value = a.values.Valid(info)
+	}
+	a.suggestions[key] = value
+	return value
+}
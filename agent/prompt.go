@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/llm"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/prompts"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// recentTurns is how many of the latest iterations are shown to the LLM in
+// full; older ones are reduced to one line each
+const recentTurns = 3
+
+// Caps on what a turn shows of a response and what its summary line shows
+const (
+	maxTurnResponse = 800
+	maxSummaryText  = 120
+)
+
+// CheckLLMConfig reports whether this server accepts a request's llm block
+func CheckLLMConfig(cfg models.LLMConfig) error {
+	if _, err := llm.ResolveSettings(cfg); err != nil {
+		return err
+	}
+	_, err := prompts.System(cfg.PromptVariant)
+	return err
+}
+
+// render renders a prompt template and remembers which version the run used
+func (a *DeepseekAgent) render(name string, data interface{}) (string, error) {
+	text, version, err := prompts.Render(name, data)
+	if err != nil {
+		return "", err
+	}
+	a.promptVersions[name] = version
+	return text, nil
+}
+
+// turn is one iteration as the LLM sees it: the action it chose and what
+// came of it
+type turn struct {
+	step     int         // position in the history; free actions get their own step
+	action   llm.Action  // nil if no usable action was given
+	request  interface{} // body sent, nil if nothing was sent
+	sent     bool
+	status   int
+	response string
+	notes    []string // analysis, observations and rejections, in order
+}
+
+// beginTurn starts recording a new iteration. Steps are numbered on their
+// own because actions that send nothing do not use up an iteration.
+func (a *DeepseekAgent) beginTurn() {
+	a.turn = &turn{step: len(a.history) + 1}
+	a.history = append(a.history, a.turn)
+}
+
+// note attaches a message for the LLM to the current iteration
+func (a *DeepseekAgent) note(text string) {
+	if a.turn == nil {
+		a.beginTurn()
+	}
+	a.turn.notes = append(a.turn.notes, text)
+}
+
+// recordExchange attaches a request the LLM's action sent, and its response,
+// to the current iteration
+func (a *DeepseekAgent) recordExchange(requestBody interface{}, resp *models.HTTPResponse) {
+	if a.turn == nil {
+		return
+	}
+	a.turn.request = requestBody
+	a.turn.sent = true
+	a.turn.status = resp.StatusCode
+	a.turn.response = truncate(string(resp.ResponseBody), maxTurnResponse)
+}
+
+// buildPrompt returns the messages for the next action: the system prompt and
+// a single snapshot of the run. The snapshot is rebuilt every turn instead of
+// growing a conversation, which keeps the prompt small and keeps each
+// response next to the request that caused it.
+func (a *DeepseekAgent) buildPrompt() ([]models.Message, error) {
+	hints := a.request.Hints
+	examples := make([]string, 0, len(hints.Examples))
+	for _, example := range hints.Examples {
+		shown, _ := a.withoutForbidden(example)
+		examples = append(examples, toJSON(shown))
+	}
+	system, err := a.render(a.systemTemplate, prompts.SystemData{
+		Method:         a.request.Method,
+		URL:            a.request.URL,
+		Description:    hints.Description,
+		Examples:       examples,
+		KnownFields:    a.priorFields,
+		RequiredFields: a.hintedRequired,
+		DoNotSend:      hints.DoNotSend,
+		HostKnowledge:  a.knowledgeNotes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := prompts.SnapshotData{
+		Method:        a.request.Method,
+		URL:           a.request.URL,
+		Step:          len(a.history),
+		Iteration:     a.iterations,
+		MaxIterations: a.request.MaxIterations,
+		StartBody:     len(a.request.InitialBody) > 0,
+		State:         a.describeState(),
+		OpenQuestions: a.openQuestions(),
+	}
+	// The current iteration has no exchange yet, so only finished ones are shown
+	past := a.history
+	if n := len(past); n > 0 && past[n-1] == a.turn {
+		past = past[:n-1]
+	}
+	for i, t := range past {
+		if i < len(past)-recentTurns {
+			data.Earlier = append(data.Earlier, t.summary())
+		} else {
+			data.Recent = append(data.Recent, t.detail())
+		}
+	}
+	snapshot, err := a.render(prompts.Snapshot, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: snapshot},
+	}, nil
+}
+
+// openQuestions lists what discovery still has to find out
+func (a *DeepseekAgent) openQuestions() []string {
+	var questions []string
+	if len(a.minimalSuccessBody) == 0 {
+		questions = append(questions, "No request has succeeded yet: which fields make a valid body?")
+	}
+
+	names := make([]string, 0, len(a.fieldStatus))
+	for name := range a.fieldStatus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		status := a.fieldStatus[name]
+		switch {
+		case status.IsInMinimalSet && status.SuccessfulTests == 0:
+			questions = append(questions, fmt.Sprintf("%s needs verification: is it required?", name))
+		case status.FailedTests > 0 && status.SuccessfulTests == 0:
+			question := fmt.Sprintf("%s has failed %d times: which value does it accept?", name, status.FailedTests)
+			if n := len(status.ValidationErrors); n > 0 {
+				question += fmt.Sprintf(" Last error: %s", truncate(status.ValidationErrors[n-1], maxSummaryText))
+			}
+			questions = append(questions, question)
+		}
+	}
+
+	if len(questions) == 0 {
+		questions = append(questions, "None: complete once you are satisfied the minimal body is verified")
+	}
+	return questions
+}
+
+// summary reduces a finished iteration to one line
+func (t *turn) summary() string {
+	name := "no action"
+	if t.action != nil {
+		name = t.action.ActionName()
+	}
+	line := fmt.Sprintf("%d. %s", t.step, name)
+	if t.sent {
+		line += fmt.Sprintf(" sent %s -> %d", truncate(toJSON(t.request), maxSummaryText), t.status)
+	}
+	if len(t.notes) > 0 {
+		first, _, _ := strings.Cut(t.notes[0], "\n")
+		line += ": " + truncate(first, maxSummaryText)
+	}
+	return line
+}
+
+// detail shows a finished iteration in full
+func (t *turn) detail() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Step %d\n", t.step)
+	if t.action != nil {
+		fmt.Fprintf(&b, "Action: %s\n", encodeActionCompact(t.action))
+	} else {
+		b.WriteString("Action: none\n")
+	}
+	if t.sent {
+		fmt.Fprintf(&b, "Sent: %s\nStatus: %d\nResponse: %s\n", toJSON(t.request), t.status, t.response)
+	}
+	for _, n := range t.notes {
+		fmt.Fprintf(&b, "Note: %s\n", n)
+	}
+	return b.String()
+}
+
+// encodeActionCompact renders an action as single-line JSON
+func encodeActionCompact(action llm.Action) string {
+	encoded := llm.EncodeAction(action)
+	var compact bytes.Buffer
+	if json.Compact(&compact, []byte(encoded)) != nil {
+		return encoded
+	}
+	return compact.String()
+}
+
+// truncate shortens text to at most max bytes, marking the cut
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	return text[:max] + "...(truncated)"
+}
@@ -0,0 +1,391 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"ai-agent-api-discovery/valuegen"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// conflictRegex matches error messages that say fields cannot be sent together
+var conflictRegex = regexp.MustCompile(`(?i)mutually exclusive|only one of|not both|cannot be (?:used|sent|provided|specified|combined) together|cannot be combined|conflicts? with`)
+
+// discriminatorNameRegex matches field names that typically select a body shape or behaviour
+var discriminatorNameRegex = regexp.MustCompile(`(?i)(?:^|_)(?:type|kind|mode|method|category|variant|channel|plan|tier|format|source)$|(?:Type|Kind|Mode|Method|Category|Variant|Channel)$`)
+
+// maxValuesPerField caps how many alternative values are tried per field
+const maxValuesPerField = 5
+
+// maxConflictCandidates caps how many optional fields are combined when looking for conflicts
+const maxConflictCandidates = 6
+
+// discoverRelationships probes a working body to find which fields are really
+// required, which fields become required depending on other fields, and which
+// fields cannot be sent together
+func (a *DeepseekAgent) discoverRelationships() {
+	if len(a.minimalSuccessBody) == 0 || !a.canProbe() {
+		return
+	}
+	utils.Logger.Printf("Probing field relationships from working body: %+v", a.minimalSuccessBody)
+
+	a.registerBodyFields(a.minimalSuccessBody)
+	a.minimizeBody()
+	a.probeValueConditions()
+	a.probeOptionalCombinations()
+
+	utils.Logger.Printf("Discovered %d field relationships", len(a.relationships))
+}
+
+// minimizeBody removes fields from the working body one at a time, keeping a
+// removal whenever the target still accepts the body. A removal that makes the
+// target ask for some other field reveals a conditional requirement.
+func (a *DeepseekAgent) minimizeBody() {
+	base := cloneBody(a.minimalSuccessBody)
+	var required []string
+
+	for _, field := range sortedKeys(base) {
+		if !a.canProbe() {
+			return
+		}
+		candidate := withoutField(base, field)
+		resp, err := a.probe(candidate)
+		if err != nil {
+			continue
+		}
+
+		status := a.fieldStatus[field]
+		if isSuccess(resp) {
+			utils.Logger.Printf("Field '%s' is optional", field)
+			base = candidate
+			status.IsInMinimalSet = false
+			continue
+		}
+		if !a.removalRejected(resp, field) {
+			// Keep the field, but the rejection says nothing about it
+			utils.Logger.Printf("Removing field '%s' was rejected for another reason (status %d)", field, resp.StatusCode)
+			continue
+		}
+
+		status.IsInMinimalSet = true
+		status.SuccessfulTests++
+		a.confirmedRequired[field] = true
+		required = append(required, field)
+		for _, fe := range a.probeErrors(resp) {
+			if fe.Rule != errparse.RuleRequired || fe.Path == field {
+				continue
+			}
+			if containsPath(candidate, fe.Path) {
+				continue
+			}
+			a.addRelationship(models.FieldRelationship{
+				Type:         models.RelationshipConditional,
+				Fields:       []string{field, fe.Path},
+				IfAbsent:     []string{field},
+				ThenRequired: []string{fe.Path},
+				Description:  fmt.Sprintf("%s is required when %s is omitted", fe.Path, field),
+			})
+		}
+	}
+
+	a.minimalSuccessBody = base
+	if len(required) > 1 {
+		a.addRelationship(models.FieldRelationship{
+			Type:        models.RelationshipMinimalSet,
+			Fields:      required,
+			Description: "fields that must be sent together",
+		})
+	}
+}
+
+// removalRejected reports whether the target's rejection of a body without
+// field shows the field is required. Conflicts, rate limits and server
+// errors say nothing about it, and neither do errors that only blame the
+// values of other fields. An error asking for another field is a
+// conditional requirement, so it counts.
+func (a *DeepseekAgent) removalRejected(resp *models.HTTPResponse, field string) bool {
+	if !isValidationFailure(resp) {
+		return false
+	}
+	errs := a.probeErrors(resp)
+	for _, fe := range errs {
+		if fe.Path == field || fe.Rule == errparse.RuleRequired {
+			return true
+		}
+	}
+	return len(errs) == 0
+}
+
+// probeValueConditions tries other values for fields of the working body and
+// records fields that become required for a particular value
+func (a *DeepseekAgent) probeValueConditions() {
+	base := a.minimalSuccessBody
+	for _, field := range sortedKeys(base) {
+		for _, value := range a.alternativeValues(field, base) {
+			if !a.canProbe() {
+				return
+			}
+			body := withField(base, field, value)
+			resp, err := a.probe(body)
+			if err != nil || isSuccess(resp) {
+				continue
+			}
+
+			var needed []string
+			for _, fe := range a.probeErrors(resp) {
+				if fe.Rule != errparse.RuleRequired || fe.Path == field {
+					continue
+				}
+				if !containsPath(body, fe.Path) {
+					needed = append(needed, fe.Path)
+				}
+			}
+			if len(needed) == 0 {
+				continue
+			}
+			sort.Strings(needed)
+			for _, name := range needed {
+				a.markFieldRequired(name)
+				a.fieldStatus[name].IsInMinimalSet = false
+			}
+			a.addRelationship(models.FieldRelationship{
+				Type:         models.RelationshipConditional,
+				Fields:       append([]string{field}, needed...),
+				If:           map[string]interface{}{field: value},
+				ThenRequired: needed,
+				Description:  fmt.Sprintf("%s required when %s=%v", strings.Join(needed, ", "), field, value),
+			})
+		}
+	}
+}
+
+// alternativeValues returns other values worth trying for a field: the rest
+// of its enum, the opposite boolean, or the enum values the target reveals
+// when sent an invalid value for discriminator-like fields
+func (a *DeepseekAgent) alternativeValues(field string, base map[string]interface{}) []interface{} {
+	current := base[field]
+	if b, ok := current.(bool); ok {
+		return []interface{}{!b}
+	}
+	if _, ok := current.(string); !ok {
+		return nil
+	}
+
+	info := a.knownFields[field]
+	if len(info.Enum) == 0 && discriminatorNameRegex.MatchString(field) {
+		a.probeEnumValues(field, base)
+	}
+
+	var values []interface{}
+	for _, v := range info.Enum {
+		if v != current && len(values) < maxValuesPerField {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// probeEnumValues sends an invalid value for a field, hoping the target lists
+// the allowed values in its error
+func (a *DeepseekAgent) probeEnumValues(field string, base map[string]interface{}) {
+	if !a.canProbe() {
+		return
+	}
+	resp, err := a.probe(withField(base, field, valuegen.NotInEnum))
+	if err != nil || isSuccess(resp) {
+		return
+	}
+	for _, fe := range a.probeErrors(resp) {
+		if fe.Path == field && fe.Rule == errparse.RuleEnum && fe.Param != "" {
+			a.knownFields[field].Enum = splitEnumValues(fe.Param)
+			utils.Logger.Printf("Field '%s' accepts values %v", field, a.knownFields[field].Enum)
+			return
+		}
+	}
+}
+
+// probeOptionalCombinations adds known optional fields to the working body,
+// first one at a time and then in pairs, to find conditional requirements
+// and fields that cannot be sent together
+func (a *DeepseekAgent) probeOptionalCombinations() {
+	base := a.minimalSuccessBody
+	var accepted []string
+
+	for _, field := range a.optionalCandidates(base) {
+		if !a.canProbe() {
+			return
+		}
+		value := a.sampleValue(field)
+		body := withField(base, field, value)
+		resp, err := a.probe(body)
+		if err != nil {
+			continue
+		}
+		if isSuccess(resp) {
+			// A lenient target accepts any field, so only an echo proves it is real
+			if a.acceptsUnknownFields("") && !echoesField(resp, field) {
+				utils.Logger.Printf("Field '%s' was accepted but not echoed; the target may be ignoring it", field)
+				continue
+			}
+			a.fieldStatus[field].SuccessfulTests++
+			accepted = append(accepted, field)
+			continue
+		}
+		a.fieldStatus[field].FailedTests++
+		a.recordCombinationFailure(resp, body, []string{field}, map[string]interface{}{field: value})
+	}
+
+	for i := 0; i < len(accepted); i++ {
+		for j := i + 1; j < len(accepted); j++ {
+			if !a.canProbe() {
+				return
+			}
+			first, second := accepted[i], accepted[j]
+			body := withField(withField(base, first, a.sampleValue(first)), second, a.sampleValue(second))
+			resp, err := a.probe(body)
+			if err != nil || isSuccess(resp) || !isValidationFailure(resp) {
+				continue
+			}
+			// A conflict needs the target to say so or to blame one of the fields
+			if conflictRegex.MatchString(string(resp.ResponseBody)) || a.blamesFields(resp, first, second) {
+				a.addRelationship(models.FieldRelationship{
+					Type:        models.RelationshipConflicts,
+					Fields:      []string{first, second},
+					Description: fmt.Sprintf("%s and %s are accepted alone but not together", first, second),
+				})
+				continue
+			}
+			values := map[string]interface{}{first: body[first], second: body[second]}
+			a.recordCombinationFailure(resp, body, []string{first, second}, values)
+		}
+	}
+}
+
+// isValidationFailure reports whether an error response can be about the body
+// sent; rate limits, server errors and duplicates say nothing about it
+func isValidationFailure(resp *models.HTTPResponse) bool {
+	return resp.StatusCode != http.StatusConflict && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500
+}
+
+// blamesFields reports whether a validation error rejects one of fields for
+// something other than a missing or duplicate value
+func (a *DeepseekAgent) blamesFields(resp *models.HTTPResponse, fields ...string) bool {
+	for _, fe := range a.probeErrors(resp) {
+		if containsString(fields, fe.Path) && fe.Rule != errparse.RuleRequired && fe.Rule != errparse.RuleUnique {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCombinationFailure interprets a rejected body that added the given
+// fields: either the target says they conflict with other fields, or it asks
+// for more fields
+func (a *DeepseekAgent) recordCombinationFailure(resp *models.HTTPResponse, body map[string]interface{}, added []string, values map[string]interface{}) {
+	text := string(resp.ResponseBody)
+	if conflictRegex.MatchString(text) {
+		fields := append([]string(nil), added...)
+		for name := range body {
+			if !containsString(added, name) && mentionsField(text, name) {
+				fields = append(fields, name)
+			}
+		}
+		if len(fields) > 1 {
+			a.addRelationship(models.FieldRelationship{
+				Type:        models.RelationshipConflicts,
+				Fields:      fields,
+				Description: fmt.Sprintf("%s cannot be sent together", strings.Join(fields, ", ")),
+			})
+			return
+		}
+	}
+
+	var needed []string
+	for _, fe := range a.probeErrors(resp) {
+		if fe.Rule == errparse.RuleRequired && !containsString(added, fe.Path) {
+			if !containsPath(body, fe.Path) {
+				needed = append(needed, fe.Path)
+			}
+		}
+	}
+	if len(needed) > 0 {
+		sort.Strings(needed)
+		a.addRelationship(models.FieldRelationship{
+			Type:         models.RelationshipConditional,
+			Fields:       append(append([]string(nil), added...), needed...),
+			If:           values,
+			ThenRequired: needed,
+			Description:  fmt.Sprintf("%s required when %s is sent", strings.Join(needed, ", "), strings.Join(added, ", ")),
+		})
+	}
+}
+
+// optionalCandidates lists known top-level fields that are not in the working
+// body and are not server-generated, capped at maxConflictCandidates
+func (a *DeepseekAgent) optionalCandidates(base map[string]interface{}) []string {
+	var candidates []string
+	for name := range a.knownFields {
+		if _, inBase := base[name]; inBase || strings.ContainsAny(name, ".[") || isServerGeneratedField(name) {
+			continue
+		}
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+	if len(candidates) > maxConflictCandidates {
+		candidates = candidates[:maxConflictCandidates]
+	}
+	return candidates
+}
+
+// sampleValue returns the known sample value of a field or a placeholder
+func (a *DeepseekAgent) sampleValue(field string) interface{} {
+	if info, exists := a.knownFields[field]; exists {
+		if info.SampleValue != nil {
+			return info.SampleValue
+		}
+		if value, remembered := a.rememberedValue(field); remembered {
+			return value
+		}
+		return a.values.Valid(*info)
+	}
+	if value, remembered := a.rememberedValue(field); remembered {
+		return value
+	}
+	return a.placeholder(field, "")
+}
+
+// addRelationship records a relationship unless an identical one is already known
+func (a *DeepseekAgent) addRelationship(rel models.FieldRelationship) {
+	key := relationshipKey(rel)
+	for _, existing := range a.relationships {
+		if relationshipKey(existing) == key {
+			return
+		}
+	}
+	utils.Logger.Printf("Found %s relationship: %s", rel.Type, rel.Description)
+	a.relationships = append(a.relationships, rel)
+}
+
+func relationshipKey(rel models.FieldRelationship) string {
+	fields := append([]string(nil), rel.Fields...)
+	sort.Strings(fields)
+	return fmt.Sprintf("%s|%v|%v|%v", rel.Type, fields, rel.If, rel.IfAbsent)
+}
+
+// mentionsField reports whether an error text mentions a field name as a word
+func mentionsField(text, field string) bool {
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(field) + `\b`).MatchString(text)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
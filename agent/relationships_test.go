@@ -0,0 +1,221 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"ai-agent-api-discovery/valuegen"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func init() {
+	utils.Logger = log.New(io.Discard, "", 0)
+}
+
+// targetFunc answers a request body with a status and a JSON reply
+type targetFunc func(body map[string]interface{}) (int, interface{})
+
+// newTestAgent returns an agent whose target is served by handle, with
+// working as its working body. Retries are off so every probe reaches the
+// handler once.
+func newTestAgent(t *testing.T, handle targetFunc, working map[string]interface{}) *DeepseekAgent {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		status, reply := handle(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(reply)
+	}))
+	t.Cleanup(server.Close)
+
+	req := models.DiscoverRequest{
+		URL:       server.URL + "/api/users",
+		Method:    "POST",
+		MaxProbes: 100,
+		Target:    models.TargetConfig{MaxRetries: -1},
+	}
+	a := &DeepseekAgent{
+		request:              req,
+		ctx:                  context.Background(),
+		knownFields:          make(map[string]*models.FieldInfo),
+		fieldStatus:          make(map[string]*FieldTestStatus),
+		minimalSuccessBody:   deepCopyBody(working),
+		target:               utils.NewTargetClient(req.Target),
+		values:               valuegen.New(1),
+		suggestions:          make(map[string]interface{}),
+		additionalProperties: make(map[string]*bool),
+		confirmedRequired:    make(map[string]bool),
+		dialects:             make(map[string]int),
+	}
+	a.registerBodyFields(working)
+	return a
+}
+
+// invalid is a DRF-style validation error for one field
+func invalid(field, message string) (int, interface{}) {
+	return http.StatusBadRequest, map[string][]string{field: {message}}
+}
+
+// unavailable is the reply of a target that failed to handle the request
+func unavailable() (int, interface{}) {
+	return http.StatusServiceUnavailable, map[string]string{"detail": "upstream timed out"}
+}
+
+// created echoes a body the target accepted
+func created(body map[string]interface{}) (int, interface{}) {
+	return http.StatusCreated, body
+}
+
+// relationshipKinds lists relationships as "type: fields" for comparison
+func relationshipKinds(rels []models.FieldRelationship) []string {
+	var kinds []string
+	for _, rel := range rels {
+		fields, _ := json.Marshal(rel.Fields)
+		kinds = append(kinds, rel.Type+": "+string(fields))
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// trueKeys lists the keys of a set in order
+func trueKeys(set map[string]bool) []string {
+	var keys []string
+	for key, ok := range set {
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMinimizeBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		handle        targetFunc
+		working       map[string]interface{}
+		wantMinimal   []string
+		wantRequired  []string
+		relationships []string
+	}{
+		{
+			name: "required fields are kept",
+			handle: func(body map[string]interface{}) (int, interface{}) {
+				for _, field := range []string{"email", "name"} {
+					if _, ok := body[field]; !ok {
+						return invalid(field, "This field is required.")
+					}
+				}
+				return created(body)
+			},
+			working:       map[string]interface{}{"email": "ada@example.com", "name": "Ada", "nickname": "ada"},
+			wantMinimal:   []string{"email", "name"},
+			wantRequired:  []string{"email", "name"},
+			relationships: []string{`part_of_minimal_set: ["email","name"]`},
+		},
+		{
+			name: "removal asks for another field",
+			handle: func(body map[string]interface{}) (int, interface{}) {
+				_, email := body["email"]
+				_, phone := body["phone"]
+				if !email && !phone {
+					return invalid("email", "This field is required.")
+				}
+				return created(body)
+			},
+			working:       map[string]interface{}{"phone": "555-0100", "name": "Ada"},
+			wantMinimal:   []string{"phone"},
+			wantRequired:  []string{"phone"},
+			relationships: []string{`conditional: ["phone","email"]`},
+		},
+		{
+			name: "server errors and conflicts say nothing",
+			handle: func(body map[string]interface{}) (int, interface{}) {
+				if _, ok := body["nickname"]; !ok {
+					return unavailable()
+				}
+				if _, ok := body["email"]; !ok {
+					return http.StatusConflict, map[string]string{"detail": "a user with this name exists"}
+				}
+				return created(body)
+			},
+			working:     map[string]interface{}{"email": "ada@example.com", "name": "Ada", "nickname": "ada"},
+			wantMinimal: []string{"email", "nickname"},
+		},
+		{
+			name: "errors about another field's value",
+			handle: func(body map[string]interface{}) (int, interface{}) {
+				if _, ok := body["country"]; !ok {
+					return invalid("postcode", "Enter a valid postcode.")
+				}
+				return created(body)
+			},
+			working:     map[string]interface{}{"country": "GB", "postcode": "SW1A 1AA"},
+			wantMinimal: []string{"country"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAgent(t, tt.handle, tt.working)
+			a.minimizeBody()
+
+			if got := sortedKeys(a.minimalSuccessBody); !reflect.DeepEqual(got, tt.wantMinimal) {
+				t.Errorf("minimal body = %v, want %v", got, tt.wantMinimal)
+			}
+			if got := trueKeys(a.confirmedRequired); !reflect.DeepEqual(got, tt.wantRequired) {
+				t.Errorf("confirmed required = %v, want %v", got, tt.wantRequired)
+			}
+			for _, field := range sortedKeys(tt.working) {
+				if inSet := a.fieldStatus[field].IsInMinimalSet; inSet != containsString(tt.wantRequired, field) {
+					t.Errorf("%s IsInMinimalSet = %v", field, inSet)
+				}
+			}
+			if got := relationshipKinds(a.relationships); !reflect.DeepEqual(got, tt.relationships) {
+				t.Errorf("relationships = %v, want %v", got, tt.relationships)
+			}
+		})
+	}
+}
+
+func TestProbeOptionalCombinations(t *testing.T) {
+	handle := func(body map[string]interface{}) (int, interface{}) {
+		if _, ok := body["referrer"]; ok {
+			return unavailable()
+		}
+		_, coupon := body["coupon"]
+		_, trial := body["trial"]
+		if coupon && trial {
+			return invalid("non_field_errors", "coupon and trial are mutually exclusive.")
+		}
+		_, vat := body["vat"]
+		_, company := body["company"]
+		if vat && !company {
+			return invalid("company", "This field is required.")
+		}
+		return created(body)
+	}
+	a := newTestAgent(t, handle, map[string]interface{}{"name": "Ada"})
+	for name, value := range map[string]interface{}{"company": "Acme", "coupon": "SPRING", "referrer": "bob", "trial": true, "vat": "GB123"} {
+		a.registerField(name, value)
+	}
+	a.probeOptionalCombinations()
+
+	want := []string{`conditional: ["vat","company"]`, `conflicts: ["coupon","trial"]`}
+	if got := relationshipKinds(a.relationships); !reflect.DeepEqual(got, want) {
+		t.Errorf("relationships = %v, want %v", got, want)
+	}
+	for field, want := range map[string][2]int{"company": {1, 0}, "coupon": {1, 0}, "referrer": {0, 1}, "trial": {1, 0}, "vat": {0, 1}} {
+		if status := a.fieldStatus[field]; status.SuccessfulTests != want[0] || status.FailedTests != want[1] {
+			t.Errorf("%s tests = %d ok, %d failed; want %v", field, status.SuccessfulTests, status.FailedTests, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"sort"
+	"strings"
+)
+
+// unknownProbeField is a field name no real API is expected to define
+const unknownProbeField = "zzUnknownProbeField"
+
+// probeAdditionalProperties adds an unknown field to the working body at the
+// top level and inside every nested object, and records whether the target
+// rejects it (strict decoding) or accepts it (unknown fields ignored or stored)
+func (a *DeepseekAgent) probeAdditionalProperties() {
+	base := a.minimalSuccessBody
+	if len(base) == 0 {
+		return
+	}
+
+	for _, path := range objectPaths(base, "") {
+		if !a.canProbe() {
+			return
+		}
+		body := deepCopyBody(base)
+		target := nestedObject(body, path)
+		if target == nil {
+			continue
+		}
+		target[unknownProbeField] = "probe"
+
+		resp, err := a.probe(body)
+		if err != nil {
+			continue
+		}
+
+		var accepted *bool
+		switch {
+		case isSuccess(resp):
+			accepted = boolPtr(true)
+		case a.rejectsUnknownField(resp):
+			accepted = boolPtr(false)
+		default:
+			utils.Logger.Printf("Unknown field probe at '%s' failed with status %d for an unrelated reason", path, resp.StatusCode)
+			continue
+		}
+		a.additionalProperties[path] = accepted
+		utils.Logger.Printf("Object '%s' accepts unknown fields: %v", path, *accepted)
+	}
+}
+
+// sortedPaths returns the object paths of probe results in order
+func sortedPaths(results map[string]*bool) []string {
+	paths := make([]string, 0, len(results))
+	for path := range results {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// rejectsUnknownField reports whether an error response blames the unknown probe field
+func (a *DeepseekAgent) rejectsUnknownField(resp *models.HTTPResponse) bool {
+	for _, fe := range a.probeErrors(resp) {
+		if fe.Rule == errparse.RuleUnknown || strings.HasSuffix(fe.Path, unknownProbeField) {
+			return true
+		}
+	}
+	return strings.Contains(string(resp.ResponseBody), unknownProbeField)
+}
+
+// acceptsUnknownFields reports whether the object at path is known to accept
+// fields it does not define, in which case a 2xx alone does not prove that a
+// newly sent field is real
+func (a *DeepseekAgent) acceptsUnknownFields(path string) bool {
+	accepted, tested := a.additionalProperties[path]
+	return tested && *accepted
+}
+
+// objectPaths lists the dotted paths of body and every object nested in it,
+// with "" for the top level
+func objectPaths(body map[string]interface{}, prefix string) []string {
+	paths := []string{prefix}
+	keys := make([]string, 0, len(body))
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if nested, ok := body[k].(map[string]interface{}); ok {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			paths = append(paths, objectPaths(nested, path)...)
+		}
+	}
+	return paths
+}
+
+// nestedObject returns the object at a dotted path inside body, or body
+// itself for the empty path
+func nestedObject(body map[string]interface{}, path string) map[string]interface{} {
+	current := body
+	if path == "" {
+		return current
+	}
+	for _, key := range strings.Split(path, ".") {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// deepCopyBody copies a body including nested objects and arrays
+func deepCopyBody(body map[string]interface{}) map[string]interface{} {
+	return deepCopyValue(body).(map[string]interface{})
+}
+
+func deepCopyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			clone[k] = deepCopyValue(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = deepCopyValue(item)
+		}
+		return clone
+	default:
+		return v
+	}
+}
+
+// echoesField reports whether a successful response contains the field, which
+// shows the target stored it rather than ignoring it
+func echoesField(resp *models.HTTPResponse, field string) bool {
+	respObj := responseObject(resp)
+	if respObj == nil {
+		return false
+	}
+	_, echoed := respObj[field]
+	return echoed
+}
@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/llm"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxFreeActions caps how many actions that send nothing may follow each
+// other without using up an iteration
+const maxFreeActions = 3
+
+// maxObservationBody caps how much of a response body is shown to the LLM
+const maxObservationBody = 4000
+
+// secretHeaderRegex matches headers whose values are not shown to the LLM
+var secretHeaderRegex = regexp.MustCompile(`(?i)authorization|cookie|token|secret|api[-_]?key`)
+
+// actionResult is what running an action produced: a response to analyse
+// or, for actions that send nothing, an observation for the LLM
+type actionResult struct {
+	response    *models.HTTPResponse
+	observation string
+}
+
+// runAction performs any action other than complete against the agent's state
+func (a *DeepseekAgent) runAction(action llm.Action) (*actionResult, error) {
+	switch act := action.(type) {
+	case *llm.ModifyFields:
+		body := cloneBody(a.currentBody)
+		for name, value := range act.Body {
+			body[name] = value
+		}
+		return a.sendBody(body)
+
+	case *llm.SendRequest:
+		return a.sendBody(act.Body)
+
+	case *llm.RemoveField:
+		body := cloneBody(a.currentBody)
+		var absent []string
+		for _, name := range act.Fields {
+			if _, present := body[name]; !present {
+				absent = append(absent, name)
+			}
+			delete(body, name)
+		}
+		if len(absent) == len(act.Fields) {
+			return &actionResult{observation: fmt.Sprintf("Nothing sent: %s not in the current body %s", strings.Join(absent, ", "), toJSON(a.currentBody))}, nil
+		}
+		return a.sendBody(body)
+
+	case *llm.SetHeader:
+		name := http.CanonicalHeaderKey(act.Name)
+		for existing := range a.headers {
+			if strings.EqualFold(existing, name) {
+				delete(a.headers, existing)
+			}
+		}
+		if act.Value == "" {
+			return &actionResult{observation: fmt.Sprintf("Header %s removed; it will not be sent from now on", name)}, nil
+		}
+		a.headers[name] = act.Value
+		return &actionResult{observation: fmt.Sprintf("Header %s set; it will be sent with every later request", name)}, nil
+
+	case *llm.TryArrayBody:
+		item := a.freshenBody(cloneBody(a.currentBody))
+		var body interface{} = []interface{}{item}
+		if act.Key != "" {
+			body = map[string]interface{}{act.Key: []interface{}{item}}
+		}
+		utils.Logger.Printf("Trying array body: %s", toJSON(body))
+		resp, err := a.sendAction(body)
+		if err != nil {
+			return nil, err
+		}
+		return &actionResult{response: resp}, nil
+
+	case *llm.InspectLastResponse:
+		return &actionResult{observation: a.describeLastResponse()}, nil
+
+	case *llm.MarkFieldOptional:
+		a.markFieldOptional(act.Field, act.Reason)
+		return &actionResult{observation: fmt.Sprintf("Recorded %s as optional", act.Field)}, nil
+
+	case *llm.AskForState:
+		return &actionResult{observation: a.describeState()}, nil
+	}
+	return nil, fmt.Errorf("unsupported action %q", action.ActionName())
+}
+
+// sendBody sends a body through executeHTTP, which also makes it the current body
+func (a *DeepseekAgent) sendBody(body map[string]interface{}) (*actionResult, error) {
+	utils.Logger.Printf("Executing HTTP request with body: %+v", body)
+	resp, err := a.executeHTTP(body)
+	if err != nil {
+		return nil, err
+	}
+	return &actionResult{response: resp}, nil
+}
+
+// markFieldOptional records the LLM's conclusion that a field is not required
+func (a *DeepseekAgent) markFieldOptional(field, reason string) {
+	a.registerField(field, a.currentBody[field])
+	status := a.fieldStatus[field]
+	status.IsInMinimalSet = false
+	if reason != "" {
+		status.ValidationErrors = append(status.ValidationErrors, "marked optional: "+reason)
+	}
+	utils.Logger.Printf("LLM marked field '%s' optional: %s", field, reason)
+}
+
+// describeLastResponse shows the last request and its full response
+func (a *DeepseekAgent) describeLastResponse() string {
+	if a.lastResponse == nil {
+		return "No request has been sent yet"
+	}
+	resp := a.lastResponse
+
+	names := make([]string, 0, len(resp.Headers))
+	for name := range resp.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var headers []string
+	for _, name := range names {
+		headers = append(headers, fmt.Sprintf("%s: %s", name, strings.Join(resp.Headers[name], ", ")))
+	}
+
+	body := truncate(string(resp.ResponseBody), maxObservationBody)
+	return fmt.Sprintf("Last request body: %s\nStatus: %d\nResponse headers:\n%s\nResponse body:\n%s",
+		toJSON(a.lastRequestBody), resp.StatusCode, strings.Join(headers, "\n"), body)
+}
+
+// describeState summarises what the agent knows, with secret header values hidden
+func (a *DeepseekAgent) describeState() string {
+	headers := make(map[string]string, len(a.headers))
+	for name, value := range a.headers {
+		if secretHeaderRegex.MatchString(name) {
+			value = "(hidden)"
+		}
+		headers[name] = value
+	}
+	return fmt.Sprintf("Current body: %s\nMinimal working body: %s\nHeaders: %s\n%s",
+		toJSON(a.currentBody), toJSON(a.minimalSuccessBody), toJSON(headers), a.getFieldStatusMessage())
+}
+
+// cloneHeaders copies a header map so the LLM's changes stay within one run
+func cloneHeaders(headers map[string]string) map[string]string {
+	clone := make(map[string]string, len(headers))
+	for name, value := range headers {
+		clone[name] = value
+	}
+	return clone
+}
+
+// toJSON renders a value compactly for messages to the LLM
+func toJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
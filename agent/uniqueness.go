@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"net/http"
+)
+
+// isConflict reports whether a response rejects a body because its values
+// clash with an existing record rather than because the body is malformed
+func isConflict(resp *models.HTTPResponse, errs []errparse.FieldError) bool {
+	if resp.StatusCode == http.StatusConflict {
+		return true
+	}
+	for _, fe := range errs {
+		if fe.Rule == errparse.RuleUnique {
+			return true
+		}
+	}
+	return false
+}
+
+// detectUniqueness resends the known-good body. If the target now rejects it
+// as a duplicate, the fields responsible are marked unique so later requests
+// get fresh values for them.
+func (a *DeepseekAgent) detectUniqueness() {
+	if len(a.minimalSuccessBody) == 0 || !a.canProbe() {
+		return
+	}
+	a.registerBodyFields(a.minimalSuccessBody)
+
+	// Send the body exactly as it succeeded, without freshening unique fields
+	resp, err := a.probeRaw(a.minimalSuccessBody)
+	if err != nil || isSuccess(resp) {
+		return
+	}
+	errs := a.probeErrors(resp)
+	if !isConflict(resp, errs) {
+		utils.Logger.Printf("Resending the working body failed with status %d, not a conflict", resp.StatusCode)
+		return
+	}
+
+	found := false
+	for _, fe := range errs {
+		if fe.Rule == errparse.RuleUnique && fe.Path != "" {
+			a.markFieldUnique(fe.Path)
+			found = true
+		}
+	}
+	if found {
+		return
+	}
+
+	// The error did not name the field, so vary one field at a time
+	for _, field := range sortedKeys(a.minimalSuccessBody) {
+		if !a.canProbe() {
+			return
+		}
+		value, ok := a.values.Fresh(a.minimalSuccessBody[field])
+		if !ok {
+			continue
+		}
+		resp, err := a.probeRaw(withField(a.minimalSuccessBody, field, value))
+		if err != nil {
+			continue
+		}
+		if isSuccess(resp) {
+			a.markFieldUnique(field)
+			return
+		}
+	}
+	utils.Logger.Printf("Target reports a conflict but no single field could be identified as unique")
+}
+
+// markFieldUnique records that a field must hold a value not already in use
+func (a *DeepseekAgent) markFieldUnique(field string) {
+	utils.Logger.Printf("Field '%s' must be unique", field)
+	if _, exists := a.knownFields[field]; !exists {
+		a.markFieldRequired(field)
+	}
+	a.knownFields[field].Unique = true
+}
+
+// freshenBody returns a copy of body with new values for every field known to
+// be unique, so repeated requests do not fail as duplicates
+func (a *DeepseekAgent) freshenBody(body map[string]interface{}) map[string]interface{} {
+	var fresh map[string]interface{}
+	for field, value := range body {
+		info, exists := a.knownFields[field]
+		if !exists || !info.Unique {
+			continue
+		}
+		if newValue, ok := a.values.Fresh(value); ok {
+			if fresh == nil {
+				fresh = cloneBody(body)
+			}
+			fresh[field] = newValue
+		}
+	}
+	if fresh == nil {
+		return body
+	}
+	return fresh
+}
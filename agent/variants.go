@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"sort"
+	"strconv"
+)
+
+// maxVariantProbes caps the probes spent discovering a single variant
+const maxVariantProbes = 6
+
+// variantState is the field set being built up for one discriminator value
+type variantState struct {
+	value  interface{}
+	body   map[string]interface{}
+	fields map[string]*models.FieldInfo
+	ok     bool
+}
+
+// discoverVariants looks for a discriminator field in the working body and,
+// if one is found, discovers the field set of every value separately so that
+// fields of different variants are not mixed together
+func (a *DeepseekAgent) discoverVariants() {
+	field := a.findDiscriminator()
+	if field == "" {
+		return
+	}
+	utils.Logger.Printf("Field '%s' looks like a discriminator with values %v", field, a.knownFields[field].Enum)
+
+	var states []*variantState
+	for _, value := range a.knownFields[field].Enum {
+		if !a.canProbe() {
+			break
+		}
+		states = append(states, a.discoverVariant(field, value))
+	}
+	if len(states) < 2 {
+		return
+	}
+
+	// Fields every discovered variant needs are shared; the rest belong to variants
+	shared := map[string]bool{}
+	first := true
+	for _, st := range states {
+		if !st.ok {
+			continue
+		}
+		if first {
+			for name := range st.fields {
+				shared[name] = true
+			}
+			first = false
+			continue
+		}
+		for name := range shared {
+			if _, ok := st.fields[name]; !ok {
+				delete(shared, name)
+			}
+		}
+	}
+
+	a.discriminator = field
+	a.variants = nil
+	a.variantFields = map[string]bool{}
+	for _, st := range states {
+		variant := models.SchemaVariant{
+			DiscriminatorValue: st.value,
+			Discovered:         st.ok,
+		}
+		if st.ok {
+			variant.MinimalRequestBody = st.body
+		}
+		names := make([]string, 0, len(st.fields))
+		for name := range st.fields {
+			if name != field && !shared[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			info := *st.fields[name]
+			info.IsInMinimalSet = true
+			info.SampleValue, _ = valueAt(st.body, name)
+			variant.Fields = append(variant.Fields, info)
+			a.variantFields[name] = true
+		}
+		a.variants = append(a.variants, variant)
+		utils.Logger.Printf("Variant %s=%v: discovered=%v, fields=%v", field, st.value, st.ok, names)
+	}
+}
+
+// findDiscriminator returns the field of the working body that selects
+// between body shapes: a string field with several known values that either
+// has a discriminator-like name or was seen to change the required fields
+func (a *DeepseekAgent) findDiscriminator() string {
+	for _, name := range sortedKeys(a.minimalSuccessBody) {
+		info, exists := a.knownFields[name]
+		if !exists || len(info.Enum) < 2 {
+			continue
+		}
+		if _, isString := a.minimalSuccessBody[name].(string); !isString {
+			continue
+		}
+		if discriminatorNameRegex.MatchString(name) {
+			return name
+		}
+		for _, rel := range a.relationships {
+			if _, ok := rel.If[name]; ok && rel.Type == models.RelationshipConditional {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// discoverVariant builds a working body for one discriminator value, starting
+// from just the discriminator and adding whatever the target says is missing
+func (a *DeepseekAgent) discoverVariant(field, value string) *variantState {
+	st := &variantState{
+		value:  value,
+		body:   map[string]interface{}{field: value},
+		fields: map[string]*models.FieldInfo{},
+	}
+
+	for i := 0; i < maxVariantProbes && a.canProbe(); i++ {
+		resp, err := a.probe(st.body)
+		if err != nil {
+			return st
+		}
+		if isSuccess(resp) {
+			st.ok = true
+			return st
+		}
+		if !a.fixVariantBody(st, a.probeErrors(resp)) {
+			utils.Logger.Printf("Variant %s=%v: no progress from error response", field, value)
+			return st
+		}
+	}
+	return st
+}
+
+// fixVariantBody adjusts a variant body according to field errors and reports
+// whether anything changed
+func (a *DeepseekAgent) fixVariantBody(st *variantState, errs []errparse.FieldError) bool {
+	changed := false
+	for _, fe := range errs {
+		if fe.Path == "" {
+			continue
+		}
+		info, exists := st.fields[fe.Path]
+		if !exists {
+			info = &models.FieldInfo{Name: fe.Path}
+			if known, ok := a.knownFields[fe.Path]; ok {
+				copied := *known
+				info = &copied
+			}
+			st.fields[fe.Path] = info
+		}
+
+		_, name := splitFieldPath(fe.Path)
+		var value interface{}
+		switch fe.Rule {
+		case errparse.RuleRequired:
+			if containsPath(st.body, fe.Path) {
+				continue
+			}
+			value = a.sampleValue(fe.Path)
+		case errparse.RuleType:
+			info.Type = fe.Param
+			value = a.placeholder(name, fe.Param)
+		case errparse.RuleFormat:
+			info.Format = fe.Param
+			value = a.placeholder(name, fe.Param)
+		case errparse.RuleEnum:
+			applyConstraint(info, fe)
+			if len(info.Enum) == 0 {
+				continue
+			}
+			value = info.Enum[0]
+		case errparse.RuleMin:
+			applyConstraint(info, fe)
+			if bound, err := strconv.ParseFloat(fe.Param, 64); err == nil {
+				value = bound + 1
+			}
+		default:
+			applyConstraint(info, fe)
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		if info.Type == "" {
+			info.Type = inferType(value)
+		}
+		if !setPath(st.body, fe.Path, value) {
+			continue
+		}
+		changed = true
+	}
+	return changed
+}
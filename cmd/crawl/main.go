@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"ai-agent-api-discovery/crawl"
+)
+
+func main() {
+	manifestPath := flag.String("manifest", "", "Path to a YAML or JSON manifest of endpoints to discover (required)")
+	outDir := flag.String("out", "schemas", "Directory for one schema per endpoint and summary.json")
+	server := flag.String("server", "", "Discovery server, overriding the manifest's (optional)")
+	concurrency := flag.Int("concurrency", 0, "Endpoints discovered at once, overriding the manifest's (optional)")
+	timeout := flag.Duration("timeout", 15*time.Minute, "Longest a single endpoint's discovery may take")
+	skipExisting := flag.Bool("skip-existing", false, "Keep schemas already in the output directory, to resume a crawl")
+	flag.Parse()
+
+	if *manifestPath == "" {
+		log.Fatal("A manifest is required. Use -manifest to provide it.")
+	}
+	manifest, err := crawl.Load(*manifestPath)
+	if err != nil {
+		log.Fatalf("Failed to load manifest: %v", err)
+	}
+	if *server != "" {
+		manifest.Server = strings.TrimRight(*server, "/")
+	}
+	if *concurrency > 0 {
+		manifest.Concurrency = *concurrency
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger.Printf("Crawling %d endpoints through %s, %d at a time", len(manifest.Endpoints), manifest.Server, manifest.Concurrency)
+	summary, err := crawl.Run(manifest, crawl.Options{
+		OutDir:       *outDir,
+		Timeout:      *timeout,
+		SkipExisting: *skipExisting,
+		Logger:       logger,
+	})
+	if err != nil {
+		log.Fatalf("Crawl failed: %v", err)
+	}
+
+	logger.Printf("Done: %d succeeded, %d failed, %d skipped, %d target requests, $%.4f estimated LLM cost",
+		summary.Succeeded, summary.Failed, summary.Skipped, summary.Requests, summary.EstimatedCost)
+	logger.Printf("Schemas and %s written to %s", crawl.SummaryFile, *outDir)
+	if summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
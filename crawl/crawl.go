@@ -0,0 +1,275 @@
+package crawl
+
+import (
+	"ai-agent-api-discovery/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses of an endpoint in a crawl summary
+const (
+	StatusOK      = "ok"      // the endpoint's schema was discovered
+	StatusFailed  = "failed"  // discovery failed; see Error and the .error.json file
+	StatusSkipped = "skipped" // a schema from an earlier crawl was kept
+)
+
+// SummaryFile is the name of the combined summary in the output directory
+const SummaryFile = "summary.json"
+
+// Options controls how a manifest is crawled
+type Options struct {
+	OutDir       string        // where schemas and the summary are written
+	Timeout      time.Duration // per endpoint, including the whole discovery run, which the server then abandons
+	SkipExisting bool          // keep schemas already in OutDir instead of discovering them again
+	Logger       *log.Logger   // progress, one line per endpoint; nil for none
+}
+
+// Result is the outcome of one endpoint
+type Result struct {
+	Name          string   `json:"name"`
+	Method        string   `json:"method"`
+	URL           string   `json:"url"`
+	Status        string   `json:"status"`
+	Error         string   `json:"error,omitempty"`
+	File          string   `json:"file,omitempty"`          // schema or error file, relative to the output directory
+	Fields        int      `json:"fields"`                  // fields discovered
+	Required      []string `json:"required,omitempty"`      // top-level fields of the minimal working body
+	Drift         int      `json:"drift,omitempty"`         // differences from the OpenAPI operation, if one was given
+	Requests      int      `json:"requests"`                // requests sent to the target, not counting retries
+	EstimatedCost float64  `json:"estimatedCost"`           // USD, as reported by the server
+	Seconds       float64  `json:"seconds"`                 // how long discovery took
+	KnowledgeHost string   `json:"knowledgeHost,omitempty"` // knowledge base entry that seeded the run, if any
+}
+
+// Summary is the combined outcome of a crawl
+type Summary struct {
+	Started       time.Time `json:"started"`
+	Finished      time.Time `json:"finished"`
+	Endpoints     int       `json:"endpoints"`
+	Succeeded     int       `json:"succeeded"`
+	Failed        int       `json:"failed"`
+	Skipped       int       `json:"skipped"`
+	Requests      int       `json:"requests"`
+	EstimatedCost float64   `json:"estimatedCost"`
+	Results       []Result  `json:"results"` // in manifest order
+}
+
+// Run discovers every endpoint of a manifest, at most m.Concurrency at once
+// and at most the host's concurrency per host, writing <name>.json per
+// endpoint (or <name>.error.json if discovery failed) and the summary
+func Run(m *Manifest, opts Options) (*Summary, error) {
+	if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Host slots are taken before crawl slots so endpoints waiting for a busy
+	// host do not hold up endpoints of other hosts
+	slots := make(chan struct{}, m.Concurrency)
+	hostSlots := make(map[string]chan struct{})
+	for host, limits := range m.Hosts {
+		if limits.Concurrency > 0 {
+			hostSlots[host] = make(chan struct{}, limits.Concurrency)
+		}
+	}
+
+	client := &http.Client{Timeout: opts.Timeout}
+	summary := &Summary{Started: time.Now().UTC(), Endpoints: len(m.Endpoints)}
+	results := make([]Result, len(m.Endpoints))
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for i, endpoint := range m.Endpoints {
+		wg.Add(1)
+		go func(i int, endpoint Endpoint) {
+			defer wg.Done()
+			if hostSlot, limited := hostSlots[endpoint.Host]; limited {
+				hostSlot <- struct{}{}
+				defer func() { <-hostSlot }()
+			}
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			result := crawlEndpoint(client, m.Server, endpoint, opts)
+			results[i] = result
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if opts.Logger != nil {
+				opts.Logger.Printf("[%d/%d] %s", done, len(m.Endpoints), describe(result))
+			}
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	summary.Finished = time.Now().UTC()
+	summary.Results = results
+	for _, result := range results {
+		switch result.Status {
+		case StatusOK:
+			summary.Succeeded++
+		case StatusFailed:
+			summary.Failed++
+		case StatusSkipped:
+			summary.Skipped++
+		}
+		summary.Requests += result.Requests
+		summary.EstimatedCost += result.EstimatedCost
+	}
+	if err := writeJSON(filepath.Join(opts.OutDir, SummaryFile), summary); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// crawlEndpoint discovers one endpoint and writes its file
+func crawlEndpoint(client *http.Client, server string, endpoint Endpoint, opts Options) Result {
+	result := Result{Name: endpoint.Name, Method: endpoint.Request.Method, URL: endpoint.Request.URL}
+	schemaFile := endpoint.Name + ".json"
+	errorFile := endpoint.Name + ".error.json"
+
+	if opts.SkipExisting {
+		if data, err := os.ReadFile(filepath.Join(opts.OutDir, schemaFile)); err == nil {
+			var schema models.DiscoveredSchema
+			if err := json.Unmarshal(data, &schema); err == nil {
+				result.Status, result.File = StatusSkipped, schemaFile
+				result.describeSchema(&schema)
+				return result
+			}
+		}
+	}
+
+	started := time.Now()
+	status, body, err := discover(client, server, endpoint.Request)
+	result.Seconds = time.Since(started).Round(time.Millisecond).Seconds()
+	if err != nil {
+		result.Status, result.Error = StatusFailed, err.Error()
+		return result
+	}
+
+	if status != http.StatusOK {
+		var failure struct {
+			Error string            `json:"error"`
+			Run   *models.RunRecord `json:"run"`
+		}
+		result.Status, result.File = StatusFailed, errorFile
+		result.Error = fmt.Sprintf("discovery server answered %d", status)
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			result.Error = failure.Error
+			result.describeRun(failure.Run)
+		}
+		if err := writeRaw(filepath.Join(opts.OutDir, errorFile), body); err != nil {
+			result.Error += "; " + err.Error()
+		}
+		return result
+	}
+
+	var schema models.DiscoveredSchema
+	if err := json.Unmarshal(body, &schema); err != nil {
+		result.Status, result.Error = StatusFailed, fmt.Sprintf("invalid schema from discovery server: %v", err)
+		return result
+	}
+	if err := writeRaw(filepath.Join(opts.OutDir, schemaFile), body); err != nil {
+		result.Status, result.Error = StatusFailed, err.Error()
+		return result
+	}
+	// A schema supersedes the error of an earlier crawl
+	if err := os.Remove(filepath.Join(opts.OutDir, errorFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		result.Error = fmt.Sprintf("failed to remove stale %s: %v", errorFile, err)
+	}
+	result.Status, result.File = StatusOK, schemaFile
+	result.describeSchema(&schema)
+	return result
+}
+
+// discover sends one discovery request to the server
+func discover(client *http.Client, server string, req models.DiscoverRequest) (int, []byte, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to encode discovery request: %w", err)
+	}
+	resp, err := client.Post(server+"/api/discover", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, fmt.Errorf("discovery request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read discovery response: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// describeSchema fills in what a result reports about a schema
+func (r *Result) describeSchema(schema *models.DiscoveredSchema) {
+	r.Fields = len(schema.Fields)
+	r.Required = make([]string, 0, len(schema.MinimalRequestBody))
+	for name := range schema.MinimalRequestBody {
+		r.Required = append(r.Required, name)
+	}
+	sort.Strings(r.Required)
+	if schema.Drift != nil {
+		r.Drift = len(schema.Drift.Items)
+	}
+	r.describeRun(schema.Run)
+}
+
+// describeRun fills in what a result reports about a run's cost
+func (r *Result) describeRun(run *models.RunRecord) {
+	if run == nil {
+		return
+	}
+	r.Requests = run.Requests
+	r.KnowledgeHost = run.KnowledgeHost
+	if run.LLM != nil {
+		r.EstimatedCost = run.LLM.EstimatedCost
+	}
+}
+
+// describe is the progress line of a result
+func describe(r Result) string {
+	switch r.Status {
+	case StatusFailed:
+		return fmt.Sprintf("%s %s %s failed: %s", r.Name, r.Method, r.URL, r.Error)
+	case StatusSkipped:
+		return fmt.Sprintf("%s skipped, %s already exists", r.Name, r.File)
+	}
+	return fmt.Sprintf("%s %s %s: %d fields, %d required, %d requests in %.1fs", r.Name, r.Method, r.URL, r.Fields, len(r.Required), r.Requests, r.Seconds)
+}
+
+// writeRaw writes a JSON response body indented
+func writeRaw(path string, body []byte) error {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		indented.Reset()
+		indented.Write(body)
+	}
+	if err := os.WriteFile(path, indented.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// writeJSON writes a value as indented JSON
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
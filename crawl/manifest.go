@@ -0,0 +1,201 @@
+// Package crawl discovers every endpoint listed in a manifest through a
+// discovery server, with bounded concurrency and per-host limits
+package crawl
+
+import (
+	"ai-agent-api-discovery/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults used when the manifest leaves them out
+const (
+	DefaultServer      = "http://localhost:8080"
+	DefaultConcurrency = 4
+)
+
+var (
+	// nameRegex matches endpoint names, which also name the output files
+	nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// unsafeRegex matches the runs of a path segment replaced in default names
+	unsafeRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// Manifest lists the endpoints of a crawl
+type Manifest struct {
+	Server      string                 `json:"server"`      // discovery server (default http://localhost:8080)
+	BaseURL     string                 `json:"baseUrl"`     // prefixed to endpoint URLs that start with "/"
+	Concurrency int                    `json:"concurrency"` // endpoints discovered at once (default 4)
+	Hosts       map[string]HostLimits  `json:"hosts"`       // limits per target host, e.g. "localhost:8081"
+	Defaults    map[string]interface{} `json:"defaults"`    // discovery request fields every endpoint starts from
+	Endpoints   []Endpoint             `json:"-"`
+}
+
+// HostLimits bounds the load a crawl puts on one target host
+type HostLimits struct {
+	Concurrency       int     `json:"concurrency"`       // endpoints of the host discovered at once (default no limit beyond the crawl's)
+	RequestsPerSecond float64 `json:"requestsPerSecond"` // applied to endpoints that set no target.requestsPerSecond
+}
+
+// Endpoint is one discovery request of a crawl
+type Endpoint struct {
+	Name    string // names the endpoint's output files
+	Host    string // target host, with port if any
+	Request models.DiscoverRequest
+}
+
+// manifestFile is the manifest as written, before endpoints are resolved
+type manifestFile struct {
+	Manifest
+	Endpoints []map[string]interface{} `json:"endpoints"`
+}
+
+// Load reads a manifest in YAML or JSON
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse reads a manifest in YAML or JSON. Each endpoint is a discovery
+// request, merged over the defaults, with an optional "name".
+func Parse(data []byte) (*Manifest, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	// Round-trip through JSON so the models' JSON names and types apply
+	normalised, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	var file manifestFile
+	if err := strictUnmarshal(normalised, &file); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	m := file.Manifest
+	if m.Server == "" {
+		m.Server = DefaultServer
+	}
+	m.Server = strings.TrimRight(m.Server, "/")
+	if m.Concurrency <= 0 {
+		m.Concurrency = DefaultConcurrency
+	}
+	hosts := make(map[string]HostLimits, len(m.Hosts))
+	for host, limits := range m.Hosts {
+		hosts[strings.ToLower(host)] = limits
+	}
+	m.Hosts = hosts
+	if len(file.Endpoints) == 0 {
+		return nil, fmt.Errorf("manifest lists no endpoints")
+	}
+
+	names := make(map[string]int)
+	for i, entry := range file.Endpoints {
+		endpoint, err := m.resolve(entry)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %d: %w", i+1, err)
+		}
+		if previous, taken := names[endpoint.Name]; taken {
+			return nil, fmt.Errorf("endpoint %d: name %q is already used by endpoint %d", i+1, endpoint.Name, previous)
+		}
+		names[endpoint.Name] = i + 1
+		m.Endpoints = append(m.Endpoints, endpoint)
+	}
+	return &m, nil
+}
+
+// resolve turns a manifest entry into a discovery request
+func (m *Manifest) resolve(entry map[string]interface{}) (Endpoint, error) {
+	var endpoint Endpoint
+	entry = merge(m.Defaults, entry)
+	if name, ok := entry["name"]; ok {
+		text, isString := name.(string)
+		if !isString {
+			return endpoint, fmt.Errorf("name must be a string")
+		}
+		endpoint.Name = text
+		delete(entry, "name")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return endpoint, err
+	}
+	if err := strictUnmarshal(data, &endpoint.Request); err != nil {
+		return endpoint, err
+	}
+	req := &endpoint.Request
+	if strings.HasPrefix(req.URL, "/") && m.BaseURL != "" {
+		req.URL = strings.TrimRight(m.BaseURL, "/") + req.URL
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return endpoint, fmt.Errorf("url %q is not an absolute URL", req.URL)
+	}
+	// The server would default the method too, but the name depends on it
+	if req.Method == "" && req.OpenAPI == nil {
+		req.Method = "POST"
+	}
+	req.Method = strings.ToUpper(req.Method)
+
+	endpoint.Host = strings.ToLower(u.Host)
+	if limits, ok := m.Hosts[endpoint.Host]; ok && req.Target.RequestsPerSecond == 0 {
+		req.Target.RequestsPerSecond = limits.RequestsPerSecond
+	}
+	if endpoint.Name == "" {
+		endpoint.Name = defaultName(req.Method, u.Path)
+	}
+	if !nameRegex.MatchString(endpoint.Name) {
+		return endpoint, fmt.Errorf("name %q may only contain letters, digits, '.', '_' and '-'", endpoint.Name)
+	}
+	return endpoint, nil
+}
+
+// defaultName names an endpoint after its method and path, e.g. post-api-users
+func defaultName(method, path string) string {
+	name := strings.ToLower(method)
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' }) {
+		segment = strings.Trim(unsafeRegex.ReplaceAllString(segment, "_"), "_")
+		if segment != "" {
+			name += "-" + segment
+		}
+	}
+	return strings.TrimPrefix(name, "-")
+}
+
+// merge overlays an endpoint entry on the defaults, merging nested objects
+// such as headers and hints key by key
+func merge(defaults, entry map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(defaults)+len(entry))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range entry {
+		base, baseIsObject := merged[key].(map[string]interface{})
+		override, isObject := value.(map[string]interface{})
+		if baseIsObject && isObject {
+			value = merge(base, override)
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// strictUnmarshal decodes JSON, rejecting unknown fields so typos in a
+// manifest are reported rather than ignored
+func strictUnmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,121 @@
+package crawl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadExampleManifest(t *testing.T) {
+	m, err := Load("../cmd/crawl/manifest.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Server != "http://localhost:8080" || m.Concurrency != 2 || len(m.Endpoints) != 4 {
+		t.Fatalf("manifest = %s, concurrency %d, %d endpoints", m.Server, m.Concurrency, len(m.Endpoints))
+	}
+	if limits := m.Hosts["localhost:8081"]; limits != (HostLimits{Concurrency: 2, RequestsPerSecond: 5}) {
+		t.Errorf("host limits = %+v", limits)
+	}
+
+	complex := m.Endpoints[1]
+	if complex.Name != "create-user-complex" || complex.Host != "localhost:8081" {
+		t.Errorf("endpoint = %s on %s", complex.Name, complex.Host)
+	}
+	req := complex.Request
+	if req.URL != "http://localhost:8081/api/users/complex" || req.Method != "POST" {
+		t.Errorf("request = %s %s", req.Method, req.URL)
+	}
+	// The endpoint overrides a default and keeps the others
+	if req.MaxIterations != 15 || req.MaxProbes != 30 || req.Target.RequestsPerSecond != 5 {
+		t.Errorf("maxIterations = %d, maxProbes = %d, requestsPerSecond = %v", req.MaxIterations, req.MaxProbes, req.Target.RequestsPerSecond)
+	}
+
+	// Nested objects are merged key by key
+	batch := m.Endpoints[3].Request
+	if batch.Budget.MaxCost != 0.05 || batch.Budget.MaxLLMCalls != 20 {
+		t.Errorf("budget = %+v", batch.Budget)
+	}
+	if m.Endpoints[0].Request.Hints.Description != "Creates a user account" {
+		t.Errorf("hints = %+v", m.Endpoints[0].Request.Hints)
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	m, err := Parse([]byte(`{
+		"hosts": {"API.example.com": {"requestsPerSecond": 2}},
+		"endpoints": [
+			{"url": "https://api.example.com/v1/users/{id}/tags", "method": "put"},
+			{"url": "https://api.example.com/orders", "target": {"requestsPerSecond": 9}},
+			{"url": "https://other.example.com:8443/"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Server != DefaultServer || m.Concurrency != DefaultConcurrency {
+		t.Errorf("server = %s, concurrency = %d", m.Server, m.Concurrency)
+	}
+	var names, hosts []string
+	var rates []float64
+	for _, endpoint := range m.Endpoints {
+		names = append(names, endpoint.Name)
+		hosts = append(hosts, endpoint.Host)
+		rates = append(rates, endpoint.Request.Target.RequestsPerSecond)
+	}
+	if want := []string{"put-v1-users-id-tags", "post-orders", "post"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	if want := []string{"api.example.com", "api.example.com", "other.example.com:8443"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("hosts = %v, want %v", hosts, want)
+	}
+	// A host's rate applies unless the endpoint sets its own
+	if want := []float64{2, 9, 0}; !reflect.DeepEqual(rates, want) {
+		t.Errorf("requestsPerSecond = %v, want %v", rates, want)
+	}
+}
+
+func TestParseRejectsInvalidManifests(t *testing.T) {
+	tests := map[string]string{
+		"no endpoints":       `server: http://localhost:8080`,
+		"unknown key":        "concurency: 2\nendpoints: [{url: 'http://a.example/x'}]",
+		"unknown field":      "endpoints: [{url: 'http://a.example/x', maxIteration: 3}]",
+		"relative URL":       "endpoints: [{url: /api/users}]",
+		"duplicate name":     "endpoints: [{url: 'http://a.example/x'}, {url: 'http://b.example/x'}]",
+		"name with slash":    "endpoints: [{name: a/b, url: 'http://a.example/x'}]",
+		"name not a string":  "endpoints: [{name: 3, url: 'http://a.example/x'}]",
+		"invalid YAML":       "endpoints: [",
+		"wrong type of knob": "endpoints: [{url: 'http://a.example/x', maxProbes: many}]",
+	}
+	for name, manifest := range tests {
+		if _, err := Parse([]byte(manifest)); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+
+	_, err := Parse([]byte("endpoints: [{url: 'http://a.example/x'}, {url: 'http://b.example/x'}]"))
+	if err == nil || !strings.Contains(err.Error(), `endpoint 2: name "post-x" is already used by endpoint 1`) {
+		t.Errorf("duplicate name error = %v", err)
+	}
+}
+
+func TestMergeNestedObjects(t *testing.T) {
+	defaults := map[string]interface{}{
+		"method":  "POST",
+		"headers": map[string]interface{}{"X-Api-Version": "2", "Accept": "application/json"},
+	}
+	entry := map[string]interface{}{
+		"headers": map[string]interface{}{"X-Api-Version": "3"},
+	}
+	want := map[string]interface{}{
+		"method":  "POST",
+		"headers": map[string]interface{}{"X-Api-Version": "3", "Accept": "application/json"},
+	}
+	if got := merge(defaults, entry); !reflect.DeepEqual(got, want) {
+		t.Errorf("merge = %v, want %v", got, want)
+	}
+	// The defaults are not changed
+	if defaults["headers"].(map[string]interface{})["X-Api-Version"] != "2" {
+		t.Error("merge changed the defaults")
+	}
+}
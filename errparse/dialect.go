@@ -0,0 +1,229 @@
+package errparse
+
+import (
+	"encoding/json"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Rules describe what a field error complains about, independent of dialect
+const (
+	RuleRequired  = "required"  // field is missing or blank
+	RuleType      = "type"      // value has the wrong type; Param holds the expected type
+	RuleEnum      = "enum"      // value is not one of the allowed values; Param lists them
+	RuleMin       = "min"       // value is below a lower bound; Param holds the bound
+	RuleMax       = "max"       // value is above an upper bound; Param holds the bound
+	RuleRange     = "range"     // value is outside a range; Param is "min..max"
+	RuleMinLength = "minLength" // value is too short; Param holds the length
+	RuleMaxLength = "maxLength" // value is too long; Param holds the length
+	RuleFormat    = "format"    // value has the wrong format; Param holds the format (email, uuid, ...)
+	RulePattern   = "pattern"   // value does not match a pattern; Param holds the pattern if known
+	RuleUnique    = "unique"    // value conflicts with an existing record
+	RuleUnknown   = "unknown"   // field is not accepted by the target
+	RuleInvalid   = "invalid"   // value was rejected for an unrecognised reason
+)
+
+// FieldError is a single field-level problem extracted from an error response
+type FieldError struct {
+	Path    string `json:"path"`            // dotted field path, e.g. profile.firstName or users[0].email
+	Rule    string `json:"rule"`            // one of the Rule constants
+	Param   string `json:"param,omitempty"` // rule parameter (expected type, bound, enum values, ...)
+	Message string `json:"message,omitempty"`
+}
+
+// Dialect extracts field errors from one framework's error format
+type Dialect struct {
+	Name         string
+	ContentTypes []string // media types that identify the dialect, tried first when the response has one
+	Parse        func(body []byte) []FieldError
+	Summarize    func(body []byte) string // optional; condenses the body into a short human-readable message
+}
+
+// Result holds the field errors found by the first matching dialect
+type Result struct {
+	Dialect string       `json:"dialect"`
+	Errors  []FieldError `json:"errors"`
+	Summary string       `json:"summary,omitempty"`
+}
+
+// registry holds the dialects in the order they are tried. Structured JSON
+// dialects come before the text ones so a JSON body is never misread as prose.
+var registry = []Dialect{
+	{Name: "problem+json", ContentTypes: []string{"application/problem+json"}, Parse: ParseProblem, Summarize: SummarizeProblem},
+	{Name: "json:api", ContentTypes: []string{"application/vnd.api+json"}, Parse: ParseJSONAPI, Summarize: SummarizeJSONAPI},
+	{Name: "pydantic", Parse: ParsePydantic},
+	{Name: "spring", Parse: ParseSpring},
+	{Name: "express-validator", Parse: ParseExpressValidator},
+	{Name: "drf", Parse: ParseDRF},
+	{Name: "rails", Parse: ParseRails},
+	{Name: "go-validator", Parse: textDialect(ParseValidatorText)},
+	{Name: "go-json", Parse: textDialect(ParseGoJSONText)},
+	{Name: "plain", Parse: textDialect(ParsePlainText)},
+}
+
+// Register adds a dialect ahead of the built-in ones. It is meant to be called
+// during program initialisation and is not safe for concurrent use.
+func Register(d Dialect) {
+	registry = append([]Dialect{d}, registry...)
+}
+
+// Dialects returns the registered dialects in the order they are tried
+func Dialects() []Dialect {
+	return append([]Dialect(nil), registry...)
+}
+
+// Parse runs the registered dialects against body and returns the result of
+// the first one that extracts at least one field error, or nil if none do
+func Parse(body []byte) *Result {
+	return ParseResponse("", body)
+}
+
+// ParseResponse is like Parse but first tries the dialects registered for the
+// response's Content-Type, so e.g. application/problem+json bodies are always
+// read as problem details
+func ParseResponse(contentType string, body []byte) *Result {
+	return parseWith(registry, contentType, body)
+}
+
+func parseWith(dialects []Dialect, contentType string, body []byte) *Result {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	ordered := make([]Dialect, 0, len(dialects))
+	for _, d := range dialects {
+		if mediaType != "" && containsFold(d.ContentTypes, mediaType) {
+			ordered = append(ordered, d)
+		}
+	}
+	for _, d := range dialects {
+		if mediaType == "" || !containsFold(d.ContentTypes, mediaType) {
+			ordered = append(ordered, d)
+		}
+	}
+
+	for _, d := range ordered {
+		if errs := d.Parse(body); len(errs) > 0 {
+			result := &Result{Dialect: d.Name, Errors: errs}
+			if d.Summarize != nil {
+				result.Summary = d.Summarize(body)
+			}
+			return result
+		}
+	}
+
+	// A problem document without field errors is still worth summarising
+	for _, d := range ordered {
+		if d.Summarize != nil && mediaType != "" && containsFold(d.ContentTypes, mediaType) {
+			if summary := d.Summarize(body); summary != "" {
+				return &Result{Dialect: d.Name, Summary: summary}
+			}
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// textDialect adapts a parser for free-text messages so it runs against both
+// plain-text bodies and the message strings of common JSON error envelopes
+func textDialect(parse func(msg string) []FieldError) func(body []byte) []FieldError {
+	return func(body []byte) []FieldError {
+		var errs []FieldError
+		for _, msg := range messageTexts(body) {
+			errs = append(errs, parse(msg)...)
+		}
+		return errs
+	}
+}
+
+// messageTexts returns the human-readable messages carried by an error body
+func messageTexts(body []byte) []string {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		text := strings.TrimSpace(string(body))
+		if text == "" {
+			return nil
+		}
+		return []string{text}
+	}
+
+	var texts []string
+	switch v := decoded.(type) {
+	case string:
+		texts = append(texts, v)
+	case map[string]interface{}:
+		for _, key := range []string{"error", "message", "detail", "errors", "msg"} {
+			switch val := v[key].(type) {
+			case string:
+				texts = append(texts, val)
+			case []interface{}:
+				for _, item := range val {
+					if s, ok := item.(string); ok {
+						texts = append(texts, s)
+					}
+				}
+			}
+		}
+	}
+	return texts
+}
+
+// decodeObject decodes body as a JSON object, returning nil if it is not one
+func decodeObject(body []byte) map[string]interface{} {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil
+	}
+	return obj
+}
+
+// joinPath renders location segments as a dotted path, using [i] for indices
+func joinPath(segments []interface{}) string {
+	var b strings.Builder
+	for _, seg := range segments {
+		switch s := seg.(type) {
+		case float64:
+			b.WriteString("[" + strconv.Itoa(int(s)) + "]")
+		case int:
+			b.WriteString("[" + strconv.Itoa(s) + "]")
+		case string:
+			if idx, err := strconv.Atoi(s); err == nil && b.Len() > 0 {
+				b.WriteString("[" + strconv.Itoa(idx) + "]")
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteString(".")
+			}
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+// stringValue renders a scalar JSON value as a string parameter
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, 0, len(val))
+		for _, item := range val {
+			parts = append(parts, stringValue(item))
+		}
+		return strings.Join(parts, ", ")
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
@@ -0,0 +1,225 @@
+package errparse
+
+import (
+	"reflect"
+	"testing"
+)
+
+// field is a FieldError without its message, which tests do not compare
+type field struct{ Path, Rule, Param string }
+
+func fields(errs []FieldError) []field {
+	out := make([]field, 0, len(errs))
+	for _, fe := range errs {
+		out = append(out, field{fe.Path, fe.Rule, fe.Param})
+	}
+	return out
+}
+
+func TestParseResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		dialect     string
+		want        []field
+	}{
+		{
+			name:        "problem+json invalid-params",
+			contentType: "application/problem+json",
+			body: `{"type": "https://example.net/validation-error", "title": "Your request parameters didn't validate.",
+				"invalid-params": [{"name": "age", "reason": "must be a positive integer"}, {"name": "color", "reason": "must be 'green', 'red' or 'blue'"}]}`,
+			dialect: "problem+json",
+			want:    []field{{"age", RuleMin, "0"}, {"color", RuleInvalid, ""}},
+		},
+		{
+			name:        "problem+json RFC 9457 errors",
+			contentType: "application/problem+json",
+			body: `{"type": "https://example.net/validation-error", "title": "Your request is not valid.", "status": 422,
+				"errors": [{"detail": "must be a positive integer", "pointer": "#/age"}, {"detail": "is required", "pointer": "#/profile/firstName"}]}`,
+			dialect: "problem+json",
+			want:    []field{{"age", RuleMin, "0"}, {"profile.firstName", RuleRequired, ""}},
+		},
+		{
+			name: "problem+json ASP.NET validation problem",
+			body: `{"type": "https://tools.ietf.org/html/rfc9110#section-15.5.1", "title": "One or more validation errors occurred.", "status": 400,
+				"errors": {"Email": ["The Email field is required."], "$.profile.FirstName": ["The FirstName field is required."]}}`,
+			dialect: "problem+json",
+			want:    []field{{"profile.firstName", RuleRequired, ""}, {"email", RuleRequired, ""}},
+		},
+		{
+			name:        "json:api",
+			contentType: "application/vnd.api+json",
+			body: `{"errors": [{"status": "422", "source": {"pointer": "/data/attributes/email"}, "title": "Invalid Attribute", "detail": "Email can't be blank"},
+				{"status": "422", "source": {"parameter": "include"}, "title": "Invalid Query Parameter"},
+				{"status": "422", "source": {"pointer": "/data/attributes/age"}, "title": "Invalid Attribute", "detail": "must be greater than 17"}]}`,
+			dialect: "json:api",
+			want:    []field{{"email", RuleRequired, ""}, {"age", RuleMin, "17"}},
+		},
+		{
+			name: "pydantic v2",
+			body: `{"detail": [{"type": "missing", "loc": ["body", "email"], "msg": "Field required", "input": {}},
+				{"type": "greater_than_equal", "loc": ["body", "age"], "msg": "Input should be greater than or equal to 18", "input": 5, "ctx": {"ge": 18}},
+				{"type": "literal_error", "loc": ["body", "items", 0, "kind"], "msg": "Input should be 'a' or 'b'", "input": "c", "ctx": {"expected": "'a' or 'b'"}}]}`,
+			dialect: "pydantic",
+			want:    []field{{"email", RuleRequired, ""}, {"age", RuleMin, "18"}, {"items[0].kind", RuleEnum, "'a' or 'b'"}},
+		},
+		{
+			name: "pydantic v1",
+			body: `{"detail": [{"loc": ["body", "name"], "msg": "field required", "type": "value_error.missing"},
+				{"loc": ["body", "name"], "msg": "ensure this value has at most 10 characters", "type": "value_error.any_str.max_length", "ctx": {"limit_value": 10}}]}`,
+			dialect: "pydantic",
+			want:    []field{{"name", RuleRequired, ""}, {"name", RuleMaxLength, "10"}},
+		},
+		{
+			name: "spring",
+			body: `{"timestamp": "2024-01-01T00:00:00.000+00:00", "status": 400, "error": "Bad Request",
+				"errors": [{"field": "email", "rejectedValue": null, "defaultMessage": "must not be blank", "code": "NotBlank"},
+				{"field": "name", "rejectedValue": "a", "defaultMessage": "size must be between 2 and 50", "code": "Size"},
+				{"field": "age", "rejectedValue": 3, "defaultMessage": "must be greater than or equal to 18", "code": "Min"}]}`,
+			dialect: "spring",
+			want:    []field{{"email", RuleRequired, ""}, {"name", RuleRange, "2..50"}, {"age", RuleMin, "18"}},
+		},
+		{
+			name: "express-validator",
+			body: `{"errors": [{"type": "field", "msg": "Invalid value", "path": "email", "location": "body"},
+				{"type": "field", "value": "x", "msg": "Password must be at least 8 characters", "path": "password", "location": "body"}]}`,
+			dialect: "express-validator",
+			want:    []field{{"email", RuleRequired, ""}, {"password", RuleMinLength, "8"}},
+		},
+		{
+			name:    "drf",
+			body:    `{"email": ["This field is required."], "age": ["A valid integer is required."], "profile": {"firstName": ["This field may not be blank."]}}`,
+			dialect: "drf",
+			want:    []field{{"age", RuleType, "integer"}, {"email", RuleRequired, ""}, {"profile.firstName", RuleRequired, ""}},
+		},
+		{
+			name:    "rails field map",
+			body:    `{"errors": {"email": ["can't be blank", "is invalid"], "age": ["must be greater than 17"]}}`,
+			dialect: "rails",
+			want:    []field{{"age", RuleMin, "17"}, {"email", RuleRequired, ""}, {"email", RulePattern, ""}},
+		},
+		{
+			name:    "rails full messages",
+			body:    `{"errors": ["Email can't be blank", "First name is too short (minimum is 2 characters)"]}`,
+			dialect: "rails",
+			want:    []field{{"email", RuleRequired, ""}, {"first_name", RuleMinLength, "2"}},
+		},
+		{
+			name:    "rails bare map",
+			body:    `{"email": ["has already been taken"]}`,
+			dialect: "rails",
+			want:    []field{{"email", RuleUnique, ""}},
+		},
+		{
+			name:    "go-validator via gin",
+			body:    `{"error": "Key: 'User.Email' Error:Field validation for 'Email' failed on the 'required' tag\nKey: 'User.Profile.FirstName' Error:Field validation for 'FirstName' failed on the 'min' tag"}`,
+			dialect: "go-validator",
+			want:    []field{{"email", RuleRequired, ""}, {"profile.firstName", RuleMin, ""}},
+		},
+		{
+			name:    "go-json",
+			body:    `{"error": "json: cannot unmarshal string into Go struct field User.age of type int"}`,
+			dialect: "go-json",
+			want:    []field{{"age", RuleType, "integer"}},
+		},
+		{
+			name:    "go-json unknown field",
+			body:    `json: unknown field "nickname"`,
+			dialect: "go-json",
+			want:    []field{{"nickname", RuleUnknown, ""}},
+		},
+		{
+			name:    "plain text",
+			body:    "email is required\nage must be between 18 and 99",
+			dialect: "plain",
+			want:    []field{{"email", RuleRequired, ""}, {"age", RuleRange, "18..99"}},
+		},
+		{
+			name:    "plain message in JSON envelope",
+			body:    `{"message": "username has already been taken"}`,
+			dialect: "plain",
+			want:    []field{{"username", RuleUnique, ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseResponse(tt.contentType, []byte(tt.body))
+			if result == nil {
+				t.Fatalf("no dialect matched")
+			}
+			if result.Dialect != tt.dialect {
+				t.Errorf("dialect = %q, want %q", result.Dialect, tt.dialect)
+			}
+			if got := fields(result.Errors); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseResponseNoMatch(t *testing.T) {
+	bodies := []string{
+		`{"ok": ["a", "b"]}`, // a bare string-list map is not a Rails error
+		`{"id": 1, "email": "a@example.com"}`,
+		`{"detail": "Not found."}`,
+		`This request was processed.`,
+		``,
+	}
+	for _, body := range bodies {
+		if result := Parse([]byte(body)); result != nil {
+			t.Errorf("Parse(%s) = %s %+v, want nil", body, result.Dialect, fields(result.Errors))
+		}
+	}
+}
+
+func TestProblemSummaryWithoutFieldErrors(t *testing.T) {
+	body := `{"type": "about:blank", "title": "Conflict", "status": 409, "detail": "The account is locked."}`
+	result := ParseResponse("application/problem+json", []byte(body))
+	if result == nil {
+		t.Fatal("no result for a problem document")
+	}
+	if len(result.Errors) != 0 || result.Summary != "409 - Conflict - The account is locked." {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestPointerToPath(t *testing.T) {
+	tests := map[string]string{
+		"#/age":                           "age",
+		"/data/attributes/email":          "email",
+		"/data/0/attributes/email":        "email",
+		"/items/0/qty":                    "items[0].qty",
+		"/a~1b/c~0d":                      "a/b.c~d",
+		"email":                           "email",
+		"/data/relationships/author/data": "author.data",
+	}
+	for pointer, want := range tests {
+		if got := PointerToPath(pointer); got != want {
+			t.Errorf("PointerToPath(%q) = %q, want %q", pointer, got, want)
+		}
+	}
+}
+
+func TestClassifyMessage(t *testing.T) {
+	tests := []struct {
+		msg, rule, param string
+	}{
+		{"This field is required.", RuleRequired, ""},
+		{"A valid integer is required.", RuleType, "integer"},
+		{"must be one of: admin, user", RuleEnum, "admin, user"},
+		{"Enter a valid email address.", RuleFormat, "email"},
+		{"must be at least 8 characters", RuleMinLength, "8"},
+		{"must be at least 18", RuleMin, "18"},
+		{"must be less than 100", RuleMax, "100"},
+		{"has already been taken", RuleUnique, ""},
+		{"something went wrong", RuleInvalid, ""},
+	}
+	for _, tt := range tests {
+		rule, param := ClassifyMessage(tt.msg)
+		if rule != tt.rule || param != tt.param {
+			t.Errorf("ClassifyMessage(%q) = %s %q, want %s %q", tt.msg, rule, param, tt.rule, tt.param)
+		}
+	}
+}
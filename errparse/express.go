@@ -0,0 +1,39 @@
+package errparse
+
+// ParseExpressValidator extracts field errors from express-validator's
+// validationResult().array() output, {"errors": [{"msg": ..., "path": ..., "location": ...}]}.
+// Version 6 used "param" instead of "path".
+func ParseExpressValidator(body []byte) []FieldError {
+	obj := decodeObject(body)
+	items, ok := obj["errors"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var errs []FieldError
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := entry["location"]; !ok {
+			continue
+		}
+		path, ok := entry["path"].(string)
+		if !ok {
+			if path, ok = entry["param"].(string); !ok {
+				continue
+			}
+		}
+		msg, _ := entry["msg"].(string)
+
+		fe := classified(path, msg)
+		// express-validator omits "value" when the field was not sent, so a
+		// generic "Invalid value" on a missing field means it is required
+		if _, sent := entry["value"]; !sent && fe.Rule == RuleInvalid {
+			fe.Rule = RuleRequired
+		}
+		errs = append(errs, fe)
+	}
+	return errs
+}
@@ -0,0 +1,146 @@
+package errparse
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// railsFullMessageRegex splits a Rails full message ("Email can't be blank")
+// into the humanised attribute and the message fragment
+var railsFullMessageRegex = regexp.MustCompile(`^([A-Z][a-z0-9]*(?: [a-z0-9]+)*?) ((?:can't|cannot|is|are|has|have|must|should|doesn't|does not|needs) .+)$`)
+
+// fieldMessages is a map of field names to message lists, the shape shared by
+// Django REST Framework serializer errors and Rails model errors
+type fieldMessages map[string]interface{}
+
+// ParseDRF extracts field errors from Django REST Framework serializer errors,
+// {"email": ["This field is required."], "profile": {"firstName": [...]}}.
+// DRF messages are full sentences, which is how this is told apart from Rails.
+func ParseDRF(body []byte) []FieldError {
+	obj := decodeObject(body)
+	if obj == nil {
+		return nil
+	}
+	delete(obj, "non_field_errors")
+	delete(obj, "detail")
+	errs := flattenFieldMessages(fieldMessages(obj), nil)
+	for _, fe := range errs {
+		if !isSentence(fe.Message) {
+			return nil
+		}
+	}
+	return errs
+}
+
+// ParseRails extracts field errors from Rails model errors, either as a field
+// map ({"errors": {"email": ["can't be blank"]}}), a bare field map, or a list
+// of full messages ({"errors": ["Email can't be blank"]})
+func ParseRails(body []byte) []FieldError {
+	obj := decodeObject(body)
+	if obj == nil {
+		return nil
+	}
+
+	switch errors := obj["errors"].(type) {
+	case map[string]interface{}:
+		return flattenFieldMessages(fieldMessages(errors), nil)
+	case []interface{}:
+		var errs []FieldError
+		for _, item := range errors {
+			msg, ok := item.(string)
+			if !ok {
+				continue
+			}
+			m := railsFullMessageRegex.FindStringSubmatch(msg)
+			if m == nil {
+				continue
+			}
+			fe := classified(railsAttribute(m[1]), m[2])
+			fe.Message = msg
+			errs = append(errs, fe)
+		}
+		return errs
+	}
+
+	errs := flattenFieldMessages(fieldMessages(obj), nil)
+	for _, fe := range errs {
+		if isSentence(fe.Message) {
+			return nil
+		}
+	}
+	// A bare map of string lists is common in non-error responses too, so at
+	// least one message has to read like a validation error
+	if !anyRecognised(errs) {
+		return nil
+	}
+	return errs
+}
+
+// anyRecognised reports whether a message of errs was classified as a known rule
+func anyRecognised(errs []FieldError) bool {
+	for _, fe := range errs {
+		if fe.Rule != RuleInvalid {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenFieldMessages walks a (possibly nested) field message map and returns
+// one FieldError per message. It returns nil if any value has an unexpected
+// shape, so arbitrary JSON objects are not mistaken for error maps.
+func flattenFieldMessages(messages fieldMessages, prefix []interface{}) []FieldError {
+	keys := make([]string, 0, len(messages))
+	for k := range messages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []FieldError
+	for _, key := range keys {
+		path := append(append([]interface{}(nil), prefix...), key)
+		switch v := messages[key].(type) {
+		case []interface{}:
+			for i, item := range v {
+				switch entry := item.(type) {
+				case string:
+					errs = append(errs, classified(joinPath(path), entry))
+				case map[string]interface{}:
+					// DRF reports errors for list items as a list of per-item maps
+					nested := flattenFieldMessages(fieldMessages(entry), append(path, i))
+					if nested == nil && len(entry) > 0 {
+						return nil
+					}
+					errs = append(errs, nested...)
+				default:
+					return nil
+				}
+			}
+		case map[string]interface{}:
+			nested := flattenFieldMessages(fieldMessages(v), path)
+			if nested == nil {
+				return nil
+			}
+			errs = append(errs, nested...)
+		default:
+			return nil
+		}
+	}
+	return errs
+}
+
+// isSentence reports whether msg is a capitalised sentence ending in a full stop
+func isSentence(msg string) bool {
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		return false
+	}
+	return unicode.IsUpper([]rune(msg)[0]) && strings.HasSuffix(msg, ".")
+}
+
+// railsAttribute converts a humanised attribute name back to its snake_case form
+func railsAttribute(human string) string {
+	return strings.ReplaceAll(strings.ToLower(human), " ", "_")
+}
@@ -0,0 +1,74 @@
+package errparse
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// goJSONFieldRegex matches encoding/json type errors on a struct field, e.g.
+	// json: cannot unmarshal string into Go struct field User.age of type int
+	goJSONFieldRegex = regexp.MustCompile(`json: cannot unmarshal (\w+) into Go struct field ([\w.\[\]]+) of type ([\w.\[\]*]+)`)
+	// goJSONValueRegex matches type errors on the top-level value, e.g.
+	// json: cannot unmarshal object into Go value of type []testapi.User
+	goJSONValueRegex = regexp.MustCompile(`json: cannot unmarshal (\w+) into Go value of type ([\w.\[\]*]+)`)
+	// goJSONUnknownRegex matches DisallowUnknownFields errors
+	goJSONUnknownRegex = regexp.MustCompile(`json: unknown field "([^"]+)"`)
+)
+
+// ParseGoJSONText extracts field errors from encoding/json decode errors
+func ParseGoJSONText(msg string) []FieldError {
+	var errs []FieldError
+	for _, m := range goJSONFieldRegex.FindAllStringSubmatch(msg, -1) {
+		path := m[2]
+		if idx := strings.Index(path, "."); idx >= 0 {
+			path = path[idx+1:]
+		}
+		errs = append(errs, FieldError{
+			Path:    path,
+			Rule:    RuleType,
+			Param:   jsonTypeForGo(m[3]),
+			Message: m[0],
+		})
+	}
+	for _, m := range goJSONValueRegex.FindAllStringSubmatch(msg, -1) {
+		errs = append(errs, FieldError{
+			Path:    "",
+			Rule:    RuleType,
+			Param:   jsonTypeForGo(m[2]),
+			Message: m[0],
+		})
+	}
+	for _, m := range goJSONUnknownRegex.FindAllStringSubmatch(msg, -1) {
+		errs = append(errs, FieldError{
+			Path:    m[1],
+			Rule:    RuleUnknown,
+			Message: m[0],
+		})
+	}
+	return errs
+}
+
+// jsonTypeForGo maps a Go type name from a decode error to a JSON type
+func jsonTypeForGo(goType string) string {
+	goType = strings.TrimPrefix(goType, "*")
+	switch {
+	case strings.HasPrefix(goType, "[]"), strings.HasPrefix(goType, "["):
+		return "array"
+	case strings.HasPrefix(goType, "map["):
+		return "object"
+	case strings.HasPrefix(goType, "int"), strings.HasPrefix(goType, "uint"):
+		return "integer"
+	case strings.HasPrefix(goType, "float"):
+		return "number"
+	case goType == "string":
+		return "string"
+	case goType == "bool":
+		return "boolean"
+	case goType == "time.Time":
+		return "date"
+	default:
+		// Named struct types such as testapi.Profile
+		return "object"
+	}
+}
@@ -0,0 +1,70 @@
+package errparse
+
+import "strings"
+
+// ParseJSONAPI extracts field errors from JSON:API error documents,
+// {"errors": [{"status": "422", "source": {"pointer": "/data/attributes/email"}, "detail": ...}]}.
+// Errors whose source is a query parameter rather than a body pointer are skipped.
+func ParseJSONAPI(body []byte) []FieldError {
+	obj := decodeObject(body)
+	items, ok := obj["errors"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var errs []FieldError
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		source, ok := entry["source"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pointer, ok := source["pointer"].(string)
+		if !ok {
+			continue
+		}
+
+		msg := firstString(entry, "detail", "title")
+		fe := classified(PointerToPath(pointer), msg)
+		if fe.Rule == RuleInvalid {
+			// Titles are often more telling than details ("Missing attribute")
+			if title, ok := entry["title"].(string); ok && title != msg {
+				fe.Rule, fe.Param = ClassifyMessage(title)
+			}
+		}
+		errs = append(errs, fe)
+	}
+	return errs
+}
+
+// SummarizeJSONAPI renders the titles and details of a JSON:API error document
+func SummarizeJSONAPI(body []byte) string {
+	obj := decodeObject(body)
+	items, ok := obj["errors"].([]interface{})
+	if !ok {
+		return ""
+	}
+	var parts []string
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := entry["source"]; !ok {
+			continue
+		}
+		var text []string
+		for _, key := range []string{"title", "detail"} {
+			if s, ok := entry[key].(string); ok && s != "" {
+				text = append(text, s)
+			}
+		}
+		if len(text) > 0 {
+			parts = append(parts, strings.Join(text, ": "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,126 @@
+package errparse
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	numberRegex  = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+	betweenRegex = regexp.MustCompile(`(?i)between\s+(-?\d+(?:\.\d+)?)\s+and\s+(-?\d+(?:\.\d+)?)`)
+	oneOfRegex   = regexp.MustCompile(`(?i)(?:one of|permitted|allowed values?(?: are)?|valid choices? (?:are|is))[:\s]*\[?([^\]]+?)\]?\.?$`)
+	quotedRegex  = regexp.MustCompile(`["'](.+)["']`)
+)
+
+// messageRule pairs a set of phrases with the rule they indicate
+type messageRule struct {
+	phrases []string
+	rule    string
+	param   func(msg string) string
+}
+
+// messageRules is checked in order; the first rule with a matching phrase wins.
+// Uniqueness and unknown-field phrases come first because they often also
+// contain words like "field" or "invalid", and type and format phrases come
+// before "required" because DRF says "A valid integer is required."
+var messageRules = []messageRule{
+	{phrases: []string{"already exists", "already been taken", "already taken", "already in use", "already registered", "duplicate", "must be unique"}, rule: RuleUnique},
+	{phrases: []string{"unknown field", "unrecognized field", "unrecognised field", "extra fields not permitted", "extra inputs are not permitted", "additional properties", "not allowed to be present", "unexpected field"}, rule: RuleUnknown},
+	{phrases: []string{"not included in the list", "is not a valid choice", "must be one of", "is not one of", "permitted:", "allowed values", "valid choices"}, rule: RuleEnum, param: enumParam},
+	{phrases: []string{"is not a number", "must be a number", "a valid number", "must be numeric"}, rule: RuleType, param: constParam("number")},
+	{phrases: []string{"valid integer", "must be an integer", "not an integer", "must be an int"}, rule: RuleType, param: constParam("integer")},
+	{phrases: []string{"must be a string", "valid string", "not a string"}, rule: RuleType, param: constParam("string")},
+	{phrases: []string{"must be a boolean", "valid boolean", "must be a bool", "not a boolean"}, rule: RuleType, param: constParam("boolean")},
+	{phrases: []string{"must be an array", "expected a list", "must be a list", "valid list", "not an array"}, rule: RuleType, param: constParam("array")},
+	{phrases: []string{"must be an object", "expected a dictionary", "valid dictionary", "not an object"}, rule: RuleType, param: constParam("object")},
+	{phrases: []string{"valid email", "email address", "invalid email", "well-formed email"}, rule: RuleFormat, param: constParam("email")},
+	{phrases: []string{"valid url", "valid uri", "invalid url"}, rule: RuleFormat, param: constParam("url")},
+	{phrases: []string{"valid uuid", "invalid uuid"}, rule: RuleFormat, param: constParam("uuid")},
+	{phrases: []string{"valid date", "invalid date", "date has wrong format", "datetime has wrong format"}, rule: RuleFormat, param: constParam("date")},
+	{phrases: []string{"is required", "are required", "field required", "can't be blank", "cannot be blank", "must not be blank", "may not be blank", "must not be null", "may not be null", "must not be empty", "cannot be empty", "is mandatory", "missing"}, rule: RuleRequired},
+	{phrases: []string{"between"}, rule: RuleRange, param: rangeParam},
+	{phrases: []string{"too short", "at least", "minimum length", "min length"}, rule: RuleMinLength, param: firstNumber},
+	{phrases: []string{"too long", "at most", "maximum length", "max length"}, rule: RuleMaxLength, param: firstNumber},
+	{phrases: []string{"must be positive", "must be a positive", "positive number", "positive integer"}, rule: RuleMin, param: constParam("0")},
+	{phrases: []string{"greater than", "minimum is", "must be >", "not be less than"}, rule: RuleMin, param: firstNumber},
+	{phrases: []string{"less than", "must be negative", "maximum is", "must be <", "not be greater than"}, rule: RuleMax, param: firstNumber},
+	{phrases: []string{"must match", "does not match", "invalid format", "wrong format", "is invalid"}, rule: RulePattern, param: quotedParam},
+}
+
+// ClassifyMessage maps a free-text validation message to a rule and parameter.
+// Messages that match nothing are classified as RuleInvalid.
+func ClassifyMessage(msg string) (rule, param string) {
+	lower := strings.ToLower(msg)
+	for _, mr := range messageRules {
+		for _, phrase := range mr.phrases {
+			if strings.Contains(lower, phrase) {
+				if mr.param != nil {
+					param = mr.param(msg)
+				}
+				return lengthAware(mr.rule, lower), param
+			}
+		}
+	}
+	return RuleInvalid, ""
+}
+
+// lengthAware turns value bounds into length bounds when the message talks
+// about characters or size, and vice versa
+func lengthAware(rule, lower string) string {
+	aboutLength := strings.Contains(lower, "character") || strings.Contains(lower, "length") ||
+		strings.Contains(lower, "size") || strings.Contains(lower, "items") || strings.Contains(lower, "elements")
+	switch rule {
+	case RuleMin:
+		if aboutLength {
+			return RuleMinLength
+		}
+	case RuleMax:
+		if aboutLength {
+			return RuleMaxLength
+		}
+	case RuleMinLength:
+		if !aboutLength && !strings.Contains(lower, "too short") {
+			return RuleMin
+		}
+	case RuleMaxLength:
+		if !aboutLength && !strings.Contains(lower, "too long") {
+			return RuleMax
+		}
+	}
+	return rule
+}
+
+func constParam(value string) func(string) string {
+	return func(string) string { return value }
+}
+
+func firstNumber(msg string) string {
+	return numberRegex.FindString(msg)
+}
+
+func rangeParam(msg string) string {
+	if m := betweenRegex.FindStringSubmatch(msg); m != nil {
+		return m[1] + ".." + m[2]
+	}
+	return ""
+}
+
+func enumParam(msg string) string {
+	if m := oneOfRegex.FindStringSubmatch(strings.TrimSpace(msg)); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
+
+func quotedParam(msg string) string {
+	if m := quotedRegex.FindStringSubmatch(msg); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// classified builds a FieldError for path by classifying msg
+func classified(path, msg string) FieldError {
+	rule, param := ClassifyMessage(msg)
+	return FieldError{Path: path, Rule: rule, Param: param, Message: msg}
+}
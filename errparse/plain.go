@@ -0,0 +1,35 @@
+package errparse
+
+import (
+	"regexp"
+	"strings"
+)
+
+// plainRegex matches messages that start with a field path followed by a
+// predicate, e.g. "email is required" or "user[0].email is required"
+var plainRegex = regexp.MustCompile(`^['"]?([A-Za-z_][\w.\[\]]*)['"]? (?:is|are|must|should|can't|cannot|may|has|does|needs)\b`)
+
+// plainStopWords are sentence openers that plainRegex would otherwise read as field names
+var plainStopWords = map[string]bool{
+	"this": true, "that": true, "it": true, "value": true, "input": true,
+	"request": true, "body": true, "field": true, "there": true, "payload": true,
+}
+
+// ParsePlainText extracts field errors from terse "<field> is required" style
+// messages, as produced by hand-written validation code
+func ParsePlainText(msg string) []FieldError {
+	var errs []FieldError
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSpace(line)
+		m := plainRegex.FindStringSubmatch(line)
+		if m == nil || plainStopWords[strings.ToLower(m[1])] {
+			continue
+		}
+		fe := classified(m[1], line)
+		if fe.Rule == RuleInvalid {
+			continue
+		}
+		errs = append(errs, fe)
+	}
+	return errs
+}
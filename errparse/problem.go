@@ -0,0 +1,172 @@
+package errparse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseProblem extracts field errors from RFC 7807 / RFC 9457 problem details.
+// Field-level errors are read from the common extensions:
+//   - "invalid-params": [{"name": "age", "reason": "must be a positive integer"}] (RFC 7807 example)
+//   - "errors": [{"pointer": "#/age", "detail": "must be a positive integer"}] (RFC 9457 example)
+//   - "errors": {"Email": ["The Email field is required."]} (ASP.NET ValidationProblemDetails)
+//
+// A problem without field-level errors falls back to reading its detail with
+// the text dialects.
+func ParseProblem(body []byte) []FieldError {
+	obj := decodeObject(body)
+	if !isProblem(obj) {
+		return nil
+	}
+
+	var errs []FieldError
+	if params, ok := obj["invalid-params"].([]interface{}); ok {
+		for _, item := range params {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := entry["name"].(string)
+			reason, _ := entry["reason"].(string)
+			if name == "" {
+				continue
+			}
+			errs = append(errs, classified(PointerToPath(name), reason))
+		}
+	}
+
+	switch items := obj["errors"].(type) {
+	case []interface{}:
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			path := problemErrorPath(entry)
+			if path == "" {
+				continue
+			}
+			errs = append(errs, classified(path, firstString(entry, "detail", "message", "reason", "title")))
+		}
+	case map[string]interface{}:
+		for _, fe := range flattenFieldMessages(fieldMessages(items), nil) {
+			fe.Path = aspNetPath(fe.Path)
+			errs = append(errs, fe)
+		}
+	}
+
+	if len(errs) == 0 {
+		if detail, ok := obj["detail"].(string); ok {
+			for _, parse := range []func(string) []FieldError{ParseValidatorText, ParseGoJSONText, ParsePlainText} {
+				if errs = parse(detail); len(errs) > 0 {
+					break
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// SummarizeProblem renders the title and detail of a problem document
+func SummarizeProblem(body []byte) string {
+	obj := decodeObject(body)
+	if !isProblem(obj) {
+		return ""
+	}
+	var parts []string
+	if status := stringValue(obj["status"]); status != "" {
+		parts = append(parts, status)
+	}
+	for _, key := range []string{"title", "detail"} {
+		if s, ok := obj[key].(string); ok && s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " - ")
+}
+
+// isProblem reports whether obj looks like a problem details document
+func isProblem(obj map[string]interface{}) bool {
+	if obj == nil {
+		return false
+	}
+	_, hasType := obj["type"].(string)
+	_, hasTitle := obj["title"].(string)
+	_, hasDetail := obj["detail"].(string)
+	_, hasStatus := obj["status"].(float64)
+	_, hasParams := obj["invalid-params"]
+	_, hasErrors := obj["errors"]
+	return (hasType || hasTitle) && (hasStatus || hasDetail || hasParams || hasErrors)
+}
+
+// problemErrorPath finds the field an entry of a problem's "errors" list refers to
+func problemErrorPath(entry map[string]interface{}) string {
+	if pointer, ok := entry["pointer"].(string); ok {
+		return PointerToPath(pointer)
+	}
+	if source, ok := entry["source"].(map[string]interface{}); ok {
+		if pointer, ok := source["pointer"].(string); ok {
+			return PointerToPath(pointer)
+		}
+	}
+	for _, key := range []string{"field", "name", "property", "propertyPath", "path"} {
+		if name, ok := entry[key].(string); ok && name != "" {
+			return PointerToPath(name)
+		}
+	}
+	return ""
+}
+
+// aspNetPath converts ASP.NET model state keys ("$.profile.firstName", "Email",
+// "Items[0].Qty") to JSON-style paths
+func aspNetPath(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "$"), ".")
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = lowerCamel(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// PointerToPath converts a JSON Pointer (RFC 6901), optionally in URI fragment
+// form, to a dotted field path. JSON:API document prefixes such as
+// /data/attributes are dropped. Values that are not pointers are returned as is.
+func PointerToPath(pointer string) string {
+	pointer = strings.TrimPrefix(pointer, "#")
+	if !strings.HasPrefix(pointer, "/") {
+		return pointer
+	}
+
+	segments := strings.Split(pointer[1:], "/")
+	if len(segments) >= 2 && segments[0] == "data" {
+		switch segments[1] {
+		case "attributes", "relationships":
+			segments = segments[2:]
+		default:
+			// /data/0/attributes/... in compound documents
+			if _, err := strconv.Atoi(segments[1]); err == nil && len(segments) >= 3 &&
+				(segments[2] == "attributes" || segments[2] == "relationships") {
+				segments = segments[3:]
+			}
+		}
+	} else if len(segments) == 1 && segments[0] == "data" {
+		segments = nil
+	}
+
+	path := make([]interface{}, 0, len(segments))
+	for _, seg := range segments {
+		seg = strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "/"), "~0", "~")
+		path = append(path, seg)
+	}
+	return joinPath(path)
+}
+
+// firstString returns the first non-empty string value among keys
+func firstString(entry map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := entry[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package errparse
+
+// pydanticTypes maps Pydantic error types (v1 and v2) to rules. The parameter
+// function reads the bound or expected values from the error's ctx object.
+var pydanticTypes = map[string]struct {
+	rule  string
+	param func(ctx map[string]interface{}) string
+}{
+	// Pydantic v2
+	"missing":                 {RuleRequired, nil},
+	"int_parsing":             {RuleType, typeParam("integer")},
+	"int_type":                {RuleType, typeParam("integer")},
+	"int_from_float":          {RuleType, typeParam("integer")},
+	"float_parsing":           {RuleType, typeParam("number")},
+	"float_type":              {RuleType, typeParam("number")},
+	"string_type":             {RuleType, typeParam("string")},
+	"bool_parsing":            {RuleType, typeParam("boolean")},
+	"bool_type":               {RuleType, typeParam("boolean")},
+	"list_type":               {RuleType, typeParam("array")},
+	"dict_type":               {RuleType, typeParam("object")},
+	"model_type":              {RuleType, typeParam("object")},
+	"model_attributes_type":   {RuleType, typeParam("object")},
+	"enum":                    {RuleEnum, ctxParam("expected")},
+	"literal_error":           {RuleEnum, ctxParam("expected")},
+	"string_too_short":        {RuleMinLength, ctxParam("min_length")},
+	"string_too_long":         {RuleMaxLength, ctxParam("max_length")},
+	"too_short":               {RuleMinLength, ctxParam("min_length")},
+	"too_long":                {RuleMaxLength, ctxParam("max_length")},
+	"greater_than":            {RuleMin, ctxParam("gt")},
+	"greater_than_equal":      {RuleMin, ctxParam("ge")},
+	"less_than":               {RuleMax, ctxParam("lt")},
+	"less_than_equal":         {RuleMax, ctxParam("le")},
+	"string_pattern_mismatch": {RulePattern, ctxParam("pattern")},
+	"uuid_parsing":            {RuleFormat, typeParam("uuid")},
+	"uuid_type":               {RuleFormat, typeParam("uuid")},
+	"url_parsing":             {RuleFormat, typeParam("url")},
+	"url_type":                {RuleFormat, typeParam("url")},
+	"date_parsing":            {RuleFormat, typeParam("date")},
+	"datetime_parsing":        {RuleFormat, typeParam("date")},
+	"extra_forbidden":         {RuleUnknown, nil},
+
+	// Pydantic v1
+	"value_error.missing":                  {RuleRequired, nil},
+	"type_error.none.not_allowed":          {RuleRequired, nil},
+	"type_error.integer":                   {RuleType, typeParam("integer")},
+	"type_error.float":                     {RuleType, typeParam("number")},
+	"type_error.str":                       {RuleType, typeParam("string")},
+	"type_error.bool":                      {RuleType, typeParam("boolean")},
+	"type_error.list":                      {RuleType, typeParam("array")},
+	"type_error.dict":                      {RuleType, typeParam("object")},
+	"type_error.enum":                      {RuleEnum, ctxParam("enum_values")},
+	"value_error.const":                    {RuleEnum, ctxParam("permitted")},
+	"value_error.any_str.min_length":       {RuleMinLength, ctxParam("limit_value")},
+	"value_error.any_str.max_length":       {RuleMaxLength, ctxParam("limit_value")},
+	"value_error.list.min_items":           {RuleMinLength, ctxParam("limit_value")},
+	"value_error.list.max_items":           {RuleMaxLength, ctxParam("limit_value")},
+	"value_error.number.not_gt":            {RuleMin, ctxParam("limit_value")},
+	"value_error.number.not_ge":            {RuleMin, ctxParam("limit_value")},
+	"value_error.number.not_lt":            {RuleMax, ctxParam("limit_value")},
+	"value_error.number.not_le":            {RuleMax, ctxParam("limit_value")},
+	"value_error.str.regex":                {RulePattern, ctxParam("pattern")},
+	"value_error.email":                    {RuleFormat, typeParam("email")},
+	"value_error.url":                      {RuleFormat, typeParam("url")},
+	"type_error.uuid":                      {RuleFormat, typeParam("uuid")},
+	"value_error.extra":                    {RuleUnknown, nil},
+	"value_error.datetime":                 {RuleFormat, typeParam("date")},
+	"value_error.date":                     {RuleFormat, typeParam("date")},
+	"value_error.any_str.not_blank":        {RuleRequired, nil},
+	"value_error.list.unique_items":        {RuleUnique, nil},
+	"value_error.number.not_finite_number": {RuleType, typeParam("number")},
+}
+
+// pydanticLocations are loc prefixes that name where a value came from rather
+// than a field in the body
+var pydanticLocations = map[string]bool{
+	"body": true, "query": true, "path": true, "header": true, "cookie": true,
+}
+
+// ParsePydantic extracts field errors from Pydantic / FastAPI validation
+// responses of the form {"detail": [{"loc": [...], "msg": ..., "type": ...}]}
+func ParsePydantic(body []byte) []FieldError {
+	obj := decodeObject(body)
+	detail, ok := obj["detail"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var errs []FieldError
+	for _, item := range detail {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		loc, ok := entry["loc"].([]interface{})
+		if !ok {
+			continue
+		}
+		if len(loc) > 0 {
+			if first, ok := loc[0].(string); ok && pydanticLocations[first] {
+				loc = loc[1:]
+			}
+		}
+		msg, _ := entry["msg"].(string)
+		errType, _ := entry["type"].(string)
+		ctx, _ := entry["ctx"].(map[string]interface{})
+
+		fe := FieldError{Path: joinPath(loc), Message: msg}
+		if mapped, ok := pydanticTypes[errType]; ok {
+			fe.Rule = mapped.rule
+			if mapped.param != nil {
+				fe.Param = mapped.param(ctx)
+			}
+		} else {
+			fe.Rule, fe.Param = ClassifyMessage(msg)
+		}
+		errs = append(errs, fe)
+	}
+	return errs
+}
+
+func typeParam(value string) func(map[string]interface{}) string {
+	return func(map[string]interface{}) string { return value }
+}
+
+func ctxParam(key string) func(map[string]interface{}) string {
+	return func(ctx map[string]interface{}) string {
+		return stringValue(ctx[key])
+	}
+}
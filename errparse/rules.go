@@ -0,0 +1,222 @@
+package errparse
+
+import (
+	"ai-agent-api-discovery/models"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ruleSemantics maps the semantic names accepted in error rules to rules
+var ruleSemantics = map[string]string{
+	"required":      RuleRequired,
+	"type":          RuleType,
+	"type mismatch": RuleType,
+	"enum":          RuleEnum,
+	"range":         RuleRange,
+	"min":           RuleMin,
+	"max":           RuleMax,
+	"minlength":     RuleMinLength,
+	"maxlength":     RuleMaxLength,
+	"format":        RuleFormat,
+	"pattern":       RulePattern,
+	"unique":        RuleUnique,
+	"unknown":       RuleUnknown,
+}
+
+// RulesFile is the format of a shared error rules file. Default rules apply to
+// every target; host rules apply to targets whose host (with port, if any)
+// matches the key.
+type RulesFile struct {
+	Default []models.ErrorRule            `json:"default"`
+	Hosts   map[string][]models.ErrorRule `json:"hosts"`
+}
+
+// sharedRules holds the rules loaded with LoadRulesFile
+var sharedRules RulesFile
+
+// LoadRulesFile loads shared error rules from a JSON file, validating every
+// rule. It is meant to be called once at startup.
+func LoadRulesFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read error rules file: %w", err)
+	}
+
+	var file RulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse error rules file: %w", err)
+	}
+	if _, err := CompileRules(file.Default); err != nil {
+		return fmt.Errorf("invalid default rule: %w", err)
+	}
+	hosts := make([]string, 0, len(file.Hosts))
+	for host := range file.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		if _, err := CompileRules(file.Hosts[host]); err != nil {
+			return fmt.Errorf("invalid rule for host %s: %w", host, err)
+		}
+	}
+
+	sharedRules = file
+	return nil
+}
+
+// RulesForURL returns the shared rules that apply to a target URL, host rules first
+func RulesForURL(target string) []models.ErrorRule {
+	var rules []models.ErrorRule
+	if u, err := url.Parse(target); err == nil {
+		if hostRules, ok := sharedRules.Hosts[u.Host]; ok {
+			rules = append(rules, hostRules...)
+		} else if hostRules, ok := sharedRules.Hosts[u.Hostname()]; ok {
+			rules = append(rules, hostRules...)
+		}
+	}
+	return append(rules, sharedRules.Default...)
+}
+
+// CompileRules turns user-supplied error rules into dialects
+func CompileRules(rules []models.ErrorRule) ([]Dialect, error) {
+	dialects := make([]Dialect, 0, len(rules))
+	for i, rule := range rules {
+		d, err := compileRule(rule)
+		if err != nil {
+			name := rule.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("error rule %s: %w", name, err)
+		}
+		dialects = append(dialects, d)
+	}
+	return dialects, nil
+}
+
+// ParseWithRules is like ParseResponse but tries the given custom dialects
+// first. The errors of every custom dialect that matches are combined, so
+// rules for different parts of one body work together; an error already
+// reported for the same field and rule by an earlier dialect is dropped. The
+// registered dialects are only tried when no custom dialect matches.
+func ParseWithRules(custom []Dialect, contentType string, body []byte) *Result {
+	var (
+		names []string
+		errs  []FieldError
+	)
+	seen := make(map[string]bool)
+	for _, d := range custom {
+		matched := false
+		for _, fe := range d.Parse(body) {
+			key := fe.Path + "\x00" + fe.Rule
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			errs = append(errs, fe)
+			matched = true
+		}
+		if matched {
+			names = append(names, d.Name)
+		}
+	}
+	if len(errs) > 0 {
+		return &Result{Dialect: strings.Join(names, "+"), Errors: errs}
+	}
+	return ParseResponse(contentType, body)
+}
+
+func compileRule(rule models.ErrorRule) (Dialect, error) {
+	semantic, ok := ruleSemantics[strings.ToLower(strings.TrimSpace(rule.Semantic))]
+	if !ok {
+		return Dialect{}, fmt.Errorf("unknown semantic %q", rule.Semantic)
+	}
+
+	name := "custom"
+	if rule.Name != "" {
+		name = "custom:" + rule.Name
+	}
+
+	switch {
+	case rule.Regex != "" && rule.JSONPath != "":
+		return Dialect{}, fmt.Errorf("only one of regex and jsonPath may be set")
+	case rule.Regex != "":
+		re, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return Dialect{}, fmt.Errorf("invalid regex: %w", err)
+		}
+		if re.SubexpIndex("field") == -1 {
+			return Dialect{}, fmt.Errorf("regex must have a named group \"field\"")
+		}
+		return Dialect{Name: name, Parse: regexRuleParser(re, semantic, rule.Param)}, nil
+	case rule.JSONPath != "":
+		steps, err := compileJSONPath(rule.JSONPath)
+		if err != nil {
+			return Dialect{}, err
+		}
+		return Dialect{Name: name, Parse: jsonPathRuleParser(steps, semantic, rule)}, nil
+	default:
+		return Dialect{}, fmt.Errorf("one of regex and jsonPath must be set")
+	}
+}
+
+// regexRuleParser extracts one field error per regex match
+func regexRuleParser(re *regexp.Regexp, semantic, fixedParam string) func(body []byte) []FieldError {
+	fieldIdx := re.SubexpIndex("field")
+	paramIdx := re.SubexpIndex("param")
+	return func(body []byte) []FieldError {
+		var errs []FieldError
+		for _, m := range re.FindAllStringSubmatch(string(body), -1) {
+			fe := FieldError{Path: m[fieldIdx], Rule: semantic, Param: fixedParam, Message: m[0]}
+			if paramIdx != -1 && m[paramIdx] != "" {
+				fe.Param = m[paramIdx]
+			}
+			if fe.Path != "" {
+				errs = append(errs, fe)
+			}
+		}
+		return errs
+	}
+}
+
+// jsonPathRuleParser extracts field errors from the values a JSONPath selects.
+// A selected string is taken as the field name; a selected object is read
+// through the rule's FieldKey and ParamKey.
+func jsonPathRuleParser(steps []pathStep, semantic string, rule models.ErrorRule) func(body []byte) []FieldError {
+	fieldKey := rule.FieldKey
+	if fieldKey == "" {
+		fieldKey = "field"
+	}
+	return func(body []byte) []FieldError {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil
+		}
+
+		var errs []FieldError
+		for _, value := range selectJSONPath(doc, steps) {
+			fe := FieldError{Rule: semantic, Param: rule.Param}
+			switch v := value.(type) {
+			case string:
+				fe.Path = v
+			case map[string]interface{}:
+				fe.Path = PointerToPath(stringValue(v[fieldKey]))
+				if rule.ParamKey != "" {
+					if param := stringValue(v[rule.ParamKey]); param != "" {
+						fe.Param = param
+					}
+				}
+				fe.Message = firstString(v, "message", "msg", "detail", "error", "reason")
+			}
+			if fe.Path != "" {
+				errs = append(errs, fe)
+			}
+		}
+		return errs
+	}
+}
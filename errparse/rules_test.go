@@ -0,0 +1,111 @@
+package errparse
+
+import (
+	"ai-agent-api-discovery/models"
+	"reflect"
+	"testing"
+)
+
+// readmeRules are the rules of the README's custom error rules example
+var readmeRules = []models.ErrorRule{
+	{Name: "missing", JSONPath: "$.result.missing[*]", Semantic: "required"},
+	{Name: "types", JSONPath: "$.problems[*]", FieldKey: "attr", ParamKey: "expected", Semantic: "type"},
+	{Name: "german", Regex: `Feld (?P<field>\w+) fehlt`, Semantic: "required"},
+}
+
+func TestParseWithRulesCombinesMatches(t *testing.T) {
+	custom, err := CompileRules(readmeRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"result": {"missing": ["email", "name"]}, "problems": [{"attr": "age", "expected": "integer"}], "hint": "Feld plz fehlt"}`
+
+	result := ParseWithRules(custom, "application/json", []byte(body))
+	if result == nil {
+		t.Fatal("no rule matched")
+	}
+	if result.Dialect != "custom:missing+custom:types+custom:german" {
+		t.Errorf("dialect = %q", result.Dialect)
+	}
+	want := []field{
+		{"email", RuleRequired, ""},
+		{"name", RuleRequired, ""},
+		{"age", RuleType, "integer"},
+		{"plz", RuleRequired, ""},
+	}
+	if got := fields(result.Errors); !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseWithRulesEarlierRuleWins(t *testing.T) {
+	custom, err := CompileRules([]models.ErrorRule{
+		{Name: "first", Regex: `(?P<field>\w+) bad, want (?P<param>\w+)`, Semantic: "type"},
+		{Name: "second", Regex: `(?P<field>\w+) bad`, Semantic: "type", Param: "string"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := ParseWithRules(custom, "", []byte("age bad, want integer"))
+	want := []field{{"age", RuleType, "integer"}}
+	if got := fields(result.Errors); !reflect.DeepEqual(got, want) || result.Dialect != "custom:first" {
+		t.Errorf("result = %s %+v, want custom:first %+v", result.Dialect, got, want)
+	}
+}
+
+func TestParseWithRulesFallsBackToDialects(t *testing.T) {
+	custom, err := CompileRules(readmeRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := ParseWithRules(custom, "", []byte(`{"detail": [{"loc": ["body", "email"], "msg": "Field required", "type": "missing"}]}`))
+	if result == nil || result.Dialect != "pydantic" {
+		t.Fatalf("result = %+v, want the pydantic dialect", result)
+	}
+}
+
+func TestCompileRulesRejectsInvalidRules(t *testing.T) {
+	tests := map[string]models.ErrorRule{
+		"unknown semantic":  {Regex: `(?P<field>\w+)`, Semantic: "sometimes"},
+		"no field group":    {Regex: `(\w+) missing`, Semantic: "required"},
+		"invalid regex":     {Regex: `(?P<field>`, Semantic: "required"},
+		"both matchers":     {Regex: `(?P<field>\w+)`, JSONPath: "$.a", Semantic: "required"},
+		"neither matcher":   {Semantic: "required"},
+		"relative JSONPath": {JSONPath: "errors[*]", Semantic: "required"},
+		"unclosed bracket":  {JSONPath: "$.errors[*", Semantic: "required"},
+	}
+	for name, rule := range tests {
+		if _, err := CompileRules([]models.ErrorRule{rule}); err == nil {
+			t.Errorf("%s: compiled without error", name)
+		}
+	}
+}
+
+func TestSelectJSONPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"errors": []interface{}{
+			map[string]interface{}{"field": "a"},
+			map[string]interface{}{"field": "b"},
+		},
+		"odd key": "x",
+	}
+	tests := []struct {
+		expr string
+		want []interface{}
+	}{
+		{"$.errors[0].field", []interface{}{"a"}},
+		{"$.errors[-1].field", []interface{}{"b"}},
+		{"$.errors[*].field", []interface{}{"a", "b"}},
+		{"$['odd key']", []interface{}{"x"}},
+		{"$.missing[*]", nil},
+	}
+	for _, tt := range tests {
+		steps, err := compileJSONPath(tt.expr)
+		if err != nil {
+			t.Fatalf("compileJSONPath(%q): %v", tt.expr, err)
+		}
+		if got := selectJSONPath(doc, steps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
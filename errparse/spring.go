@@ -0,0 +1,72 @@
+package errparse
+
+// springCodes maps Bean Validation constraint codes to rules
+var springCodes = map[string]string{
+	"NotNull":        RuleRequired,
+	"NotBlank":       RuleRequired,
+	"NotEmpty":       RuleRequired,
+	"Size":           RuleRange,
+	"Length":         RuleRange,
+	"Min":            RuleMin,
+	"DecimalMin":     RuleMin,
+	"Positive":       RuleMin,
+	"PositiveOrZero": RuleMin,
+	"Max":            RuleMax,
+	"DecimalMax":     RuleMax,
+	"Negative":       RuleMax,
+	"NegativeOrZero": RuleMax,
+	"Email":          RuleFormat,
+	"Pattern":        RulePattern,
+	"Past":           RuleFormat,
+	"Future":         RuleFormat,
+	"PastOrPresent":  RuleFormat,
+	"typeMismatch":   RuleType,
+}
+
+// ParseSpring extracts field errors from Spring Boot's default validation
+// response, {"status": 400, "errors": [{"field": ..., "code": ..., "defaultMessage": ...}]}
+func ParseSpring(body []byte) []FieldError {
+	obj := decodeObject(body)
+	items, ok := obj["errors"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var errs []FieldError
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		field, hasField := entry["field"].(string)
+		msg, hasMsg := entry["defaultMessage"].(string)
+		if !hasField || !hasMsg {
+			continue
+		}
+		code, _ := entry["code"].(string)
+
+		fe := classified(field, msg)
+		if rule, ok := springCodes[code]; ok {
+			fe.Rule = rule
+			switch code {
+			case "Email":
+				fe.Param = "email"
+			case "Past", "Future", "PastOrPresent":
+				fe.Param = "date"
+			case "Positive", "PositiveOrZero", "Negative", "NegativeOrZero":
+				fe.Param = "0"
+			case "Size", "Length":
+				fe.Param = rangeParam(msg)
+				if fe.Param == "" {
+					fe.Rule, fe.Param = ClassifyMessage(msg)
+				}
+			case "Min", "Max", "DecimalMin", "DecimalMax":
+				fe.Param = firstNumber(msg)
+			case "typeMismatch":
+				fe.Param = ""
+			}
+		}
+		errs = append(errs, fe)
+	}
+	return errs
+}
@@ -0,0 +1,97 @@
+package errparse
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// validatorRegex matches one go-playground/validator error line, e.g.
+// Key: 'User.Email' Error:Field validation for 'Email' failed on the 'required' tag
+var validatorRegex = regexp.MustCompile(`Key: '([^']+)' Error:Field validation for '[^']*' failed on the '([^']+)' tag`)
+
+// validatorTags maps validator tags to rules and, where it is implied, a parameter
+var validatorTags = map[string]struct{ rule, param string }{
+	"required":             {RuleRequired, ""},
+	"required_if":          {RuleRequired, ""},
+	"required_unless":      {RuleRequired, ""},
+	"required_with":        {RuleRequired, ""},
+	"required_with_all":    {RuleRequired, ""},
+	"required_without":     {RuleRequired, ""},
+	"required_without_all": {RuleRequired, ""},
+	"excluded_with":        {RuleUnknown, ""},
+	"excluded_without":     {RuleUnknown, ""},
+	"oneof":                {RuleEnum, ""},
+	"min":                  {RuleMin, ""},
+	"gte":                  {RuleMin, ""},
+	"gt":                   {RuleMin, ""},
+	"max":                  {RuleMax, ""},
+	"lte":                  {RuleMax, ""},
+	"lt":                   {RuleMax, ""},
+	"len":                  {RuleRange, ""},
+	"email":                {RuleFormat, "email"},
+	"url":                  {RuleFormat, "url"},
+	"uri":                  {RuleFormat, "url"},
+	"uuid":                 {RuleFormat, "uuid"},
+	"uuid4":                {RuleFormat, "uuid"},
+	"datetime":             {RuleFormat, "date"},
+	"e164":                 {RuleFormat, "phone"},
+	"ip":                   {RuleFormat, "ip"},
+	"ipv4":                 {RuleFormat, "ip"},
+	"ipv6":                 {RuleFormat, "ip"},
+	"hexcolor":             {RuleFormat, "color"},
+	"numeric":              {RuleType, "number"},
+	"number":               {RuleType, "number"},
+	"boolean":              {RuleType, "boolean"},
+	"alpha":                {RulePattern, "^[a-zA-Z]+$"},
+	"alphanum":             {RulePattern, "^[a-zA-Z0-9]+$"},
+	"unique":               {RuleUnique, ""},
+}
+
+// ParseValidatorText extracts field errors from go-playground/validator messages,
+// as returned verbatim by gin's binding errors. The validator message does not
+// include tag parameters, so bounds and enum values are left empty.
+func ParseValidatorText(msg string) []FieldError {
+	var errs []FieldError
+	for _, m := range validatorRegex.FindAllStringSubmatch(msg, -1) {
+		fe := FieldError{
+			Path:    validatorPath(m[1]),
+			Rule:    RuleInvalid,
+			Param:   m[2],
+			Message: m[0],
+		}
+		if mapped, ok := validatorTags[m[2]]; ok {
+			fe.Rule = mapped.rule
+			fe.Param = mapped.param
+		}
+		errs = append(errs, fe)
+	}
+	return errs
+}
+
+// validatorPath converts a validator namespace such as User.Profile.FirstName
+// into a JSON-style path (profile.firstName). The leading struct name is dropped
+// and Go field names are lower-camel-cased, which matches the usual json tags.
+func validatorPath(namespace string) string {
+	parts := strings.Split(namespace, ".")
+	if len(parts) > 1 {
+		parts = parts[1:]
+	}
+	for i, p := range parts {
+		parts[i] = lowerCamel(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// lowerCamel lowercases the leading run of capitals in a Go identifier,
+// keeping the last one if it starts the next word (URLPath -> urlPath)
+func lowerCamel(name string) string {
+	runes := []rune(name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
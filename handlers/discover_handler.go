@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"ai-agent-api-discovery/agent"
+	"ai-agent-api-discovery/errparse"
 	"ai-agent-api-discovery/models"
 	"net/http"
 
@@ -18,6 +19,25 @@ func DiscoverHandler(c *gin.Context) {
 		return
 	}
 
+	// Reject error rules that do not compile before starting the run
+	if _, err := errparse.CompileRules(req.ErrorRules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reject LLM settings outside the server's limits
+	if err := agent.CheckLLMConfig(req.LLM); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reject an OpenAPI seed whose document or operation cannot be used. This
+	// also takes the operation's method unless the request names one.
+	if err := agent.ResolveOpenAPI(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid OpenAPI seed: " + err.Error()})
+		return
+	}
+
 	// Set default values if not provided
 	if req.Method == "" {
 		req.Method = "POST"
@@ -25,6 +45,9 @@ func DiscoverHandler(c *gin.Context) {
 	if req.MaxIterations == 0 {
 		req.MaxIterations = 10
 	}
+	if req.MaxProbes == 0 {
+		req.MaxProbes = 30
+	}
 
 	// Create and run the discovery agent
 	discoveryAgent, err := agent.NewDeepseekAgent(req)
@@ -33,9 +56,11 @@ func DiscoverHandler(c *gin.Context) {
 		return
 	}
 
-	schema, err := discoveryAgent.RunDiscovery()
+	// The run stops if the client disconnects or gives up waiting
+	schema, err := discoveryAgent.RunDiscovery(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// Report what the failed run sent and spent along with the error
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "run": discoveryAgent.RunRecord()})
 		return
 	}
 
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"ai-agent-api-discovery/knowledge"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// knowledgeDisabled is returned by the knowledge endpoints when the server
+// was started without a knowledge directory
+var knowledgeDisabled = gin.H{"error": "knowledge base is disabled; start the server with -knowledge-dir"}
+
+// ListKnowledgeHandler handles the GET /api/knowledge endpoint
+func ListKnowledgeHandler(c *gin.Context) {
+	if !knowledge.Enabled() {
+		c.JSON(http.StatusNotFound, knowledgeDisabled)
+		return
+	}
+	hosts, err := knowledge.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hosts": hosts})
+}
+
+// GetKnowledgeHandler handles the GET /api/knowledge/:host endpoint
+func GetKnowledgeHandler(c *gin.Context) {
+	host, ok := knowledgeHost(c)
+	if !ok {
+		return
+	}
+	h, err := knowledge.Get(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if h == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "nothing is known about " + host})
+		return
+	}
+	c.JSON(http.StatusOK, h)
+}
+
+// DeleteKnowledgeHandler handles the DELETE /api/knowledge/:host endpoint
+func DeleteKnowledgeHandler(c *gin.Context) {
+	host, ok := knowledgeHost(c)
+	if !ok {
+		return
+	}
+	deleted, err := knowledge.Delete(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "nothing is known about " + host})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// knowledgeHost returns the host named in the path, answering the request
+// itself if the knowledge base is disabled or the host is invalid
+func knowledgeHost(c *gin.Context) (string, bool) {
+	if !knowledge.Enabled() {
+		c.JSON(http.StatusNotFound, knowledgeDisabled)
+		return "", false
+	}
+	host := c.Param("host")
+	if !knowledge.ValidHost(host) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid host " + host + "; use host or host:port"})
+		return "", false
+	}
+	return host, true
+}
@@ -0,0 +1,360 @@
+// Package knowledge keeps what discovery runs have learned about each target
+// host, in one JSON file per host, so later runs on the same host can start
+// from it instead of from nothing
+package knowledge
+
+import (
+	"ai-agent-api-discovery/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxValues caps the working values kept per field
+const maxValues = 5
+
+// hostRegex matches the host names that may name a file, with an optional port
+var hostRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.\-]*(:\d+)?$`)
+
+var (
+	// mu serialises reads and writes of the host files
+	mu sync.Mutex
+	// dir is where the host files are kept; "" disables the knowledge base
+	dir string
+)
+
+// Host is what discovery has learned about one target host
+type Host struct {
+	Host       string               `json:"host"`
+	Updated    time.Time            `json:"updated"`
+	Runs       int                  `json:"runs"`                 // successful runs recorded
+	FailedRuns int                  `json:"failedRuns,omitempty"` // failed runs; only their auth and dialects are kept
+	Fields     map[string]*Field    `json:"fields"`               // by field name, across the host's endpoints
+	Endpoints  map[string]*Endpoint `json:"endpoints"`            // by "METHOD /path"
+	Auth       Auth                 `json:"auth"`
+	// Headers are non-credential headers runs had to add, e.g. X-Api-Version
+	Headers map[string]string `json:"headers,omitempty"`
+	// Dialects counts the error responses each error dialect parsed
+	Dialects map[string]int `json:"dialects,omitempty"`
+}
+
+// Field is a field name seen on a host
+type Field struct {
+	Type      string        `json:"type,omitempty"`
+	Format    string        `json:"format,omitempty"`
+	Values    []interface{} `json:"values,omitempty"` // values the target accepted, newest first
+	Unique    bool          `json:"unique,omitempty"` // whether a value may only be used once
+	Endpoints []string      `json:"endpoints"`
+}
+
+// Endpoint is what was learned about one endpoint of a host
+type Endpoint struct {
+	MinimalBody map[string]interface{} `json:"minimalBody"`
+	Updated     time.Time              `json:"updated"`
+}
+
+// Auth describes the credentials a host needs. Credential values are never stored.
+type Auth struct {
+	Headers    []string `json:"headers,omitempty"` // credential headers sent with working requests
+	Challenged bool     `json:"challenged"`        // whether the host has answered 401 or 403
+}
+
+// Summary describes a host entry in a listing
+type Summary struct {
+	Host      string    `json:"host"`
+	Updated   time.Time `json:"updated"`
+	Runs      int       `json:"runs"`
+	Fields    int       `json:"fields"`
+	Endpoints int       `json:"endpoints"`
+}
+
+// Run is what one discovery run learned. Of a failed run only the auth
+// headers, challenges and dialects are recorded, since its fields and bodies
+// were never shown to work.
+type Run struct {
+	Failed      bool                   // whether discovery failed
+	Endpoint    string                 // "METHOD /path"
+	Fields      []models.FieldInfo     // fields with the value that worked, if any, as SampleValue
+	MinimalBody map[string]interface{} // the smallest body that worked
+	AuthHeaders []string               // names of credential headers sent
+	Headers     map[string]string      // non-credential headers the run added
+	Challenged  bool                   // whether the run got 401 or 403 responses
+	Dialects    map[string]int         // error responses parsed per dialect
+}
+
+// Open enables the knowledge base, keeping host files in path. It is meant
+// to be called once at startup.
+func Open(path string) error {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("failed to create knowledge directory: %w", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	dir = path
+	return nil
+}
+
+// Enabled reports whether a knowledge directory has been opened
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return dir != ""
+}
+
+// HostOf returns the host, with port if any, that a target URL is filed under
+func HostOf(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// EndpointOf returns the key of an endpoint within its host
+func EndpointOf(method, target string) string {
+	path := "/"
+	if u, err := url.Parse(target); err == nil && u.Path != "" {
+		path = u.Path
+	}
+	return strings.ToUpper(method) + " " + path
+}
+
+// ValidHost reports whether a host can have a knowledge base entry
+func ValidHost(host string) bool {
+	return hostRegex.MatchString(host)
+}
+
+// Get returns what is known about a host, or nil if nothing is
+func Get(host string) (*Host, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return load(host)
+}
+
+// List summarises every host in the knowledge base
+func List() ([]Summary, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if dir == "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list knowledge files: %w", err)
+	}
+
+	summaries := []Summary{}
+	for _, path := range paths {
+		h, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, Summary{Host: h.Host, Updated: h.Updated, Runs: h.Runs, Fields: len(h.Fields), Endpoints: len(h.Endpoints)})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Host < summaries[j].Host })
+	return summaries, nil
+}
+
+// Record merges what a run learned into its host's entry
+func Record(host string, run Run) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if dir == "" {
+		return nil
+	}
+	h, err := load(host)
+	if err != nil {
+		return err
+	}
+	if h == nil {
+		h = &Host{Host: host}
+	}
+	h.merge(run, time.Now().UTC())
+	return save(h)
+}
+
+// Delete forgets a host. It reports whether there was anything to forget.
+func Delete(host string) (bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	path, err := filePath(host)
+	if err != nil || path == "" {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to delete knowledge for %s: %w", host, err)
+	}
+	return true, nil
+}
+
+// Dialect returns the error dialect that parsed most of the host's errors
+func (h *Host) Dialect() string {
+	best, most := "", 0
+	for name, count := range h.Dialects {
+		if count > most || (count == most && name < best) {
+			best, most = name, count
+		}
+	}
+	return best
+}
+
+// merge adds a run to the host
+func (h *Host) merge(run Run, now time.Time) {
+	h.Updated = now
+	h.mergeAuth(run)
+	if run.Failed {
+		h.FailedRuns++
+		return
+	}
+	h.Runs++
+	if h.Fields == nil {
+		h.Fields = make(map[string]*Field)
+	}
+	if h.Endpoints == nil {
+		h.Endpoints = make(map[string]*Endpoint)
+	}
+
+	for _, info := range run.Fields {
+		field, exists := h.Fields[info.Name]
+		if !exists {
+			field = &Field{}
+			h.Fields[info.Name] = field
+		}
+		if info.Type != "" {
+			field.Type = info.Type
+		}
+		if info.Format != "" {
+			field.Format = info.Format
+		}
+		field.Unique = field.Unique || info.Unique
+		if info.SampleValue != nil {
+			field.addValue(info.SampleValue)
+		}
+		if !containsString(field.Endpoints, run.Endpoint) {
+			field.Endpoints = append(field.Endpoints, run.Endpoint)
+			sort.Strings(field.Endpoints)
+		}
+	}
+	if run.MinimalBody != nil {
+		h.Endpoints[run.Endpoint] = &Endpoint{MinimalBody: run.MinimalBody, Updated: now}
+	}
+
+	for name, value := range run.Headers {
+		if h.Headers == nil {
+			h.Headers = make(map[string]string)
+		}
+		h.Headers[name] = value
+	}
+}
+
+// mergeAuth adds what any run, failed or not, learned about the host's
+// credentials and error dialects
+func (h *Host) mergeAuth(run Run) {
+	for _, name := range run.AuthHeaders {
+		if !containsString(h.Auth.Headers, name) {
+			h.Auth.Headers = append(h.Auth.Headers, name)
+		}
+	}
+	sort.Strings(h.Auth.Headers)
+	h.Auth.Challenged = h.Auth.Challenged || run.Challenged
+	for name, count := range run.Dialects {
+		if h.Dialects == nil {
+			h.Dialects = make(map[string]int)
+		}
+		h.Dialects[name] += count
+	}
+}
+
+// addValue puts a working value first, keeping at most maxValues
+func (f *Field) addValue(value interface{}) {
+	encoded, _ := json.Marshal(value)
+	values := []interface{}{value}
+	for _, existing := range f.Values {
+		if other, _ := json.Marshal(existing); string(other) != string(encoded) && len(values) < maxValues {
+			values = append(values, existing)
+		}
+	}
+	f.Values = values
+}
+
+// load reads a host's file, or returns nil if there is none. mu must be held.
+func load(host string) (*Host, error) {
+	path, err := filePath(host)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	h, err := readFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return h, err
+}
+
+// save writes a host's file, replacing it atomically. mu must be held.
+func save(h *Host) error {
+	path, err := filePath(h.Host)
+	if err != nil || path == "" {
+		return err
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode knowledge for %s: %w", h.Host, err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write knowledge for %s: %w", h.Host, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write knowledge for %s: %w", h.Host, err)
+	}
+	return nil
+}
+
+// readFile reads one host file
+func readFile(path string) (*Host, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read knowledge file: %w", err)
+	}
+	var h Host
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("failed to parse knowledge file %s: %w", filepath.Base(path), err)
+	}
+	return &h, nil
+}
+
+// filePath returns the file of a host, or "" if the knowledge base is
+// disabled. mu must be held.
+func filePath(host string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if !ValidHost(host) {
+		return "", fmt.Errorf("invalid host %q", host)
+	}
+	return filepath.Join(dir, strings.ReplaceAll(strings.ToLower(host), ":", "_")+".json"), nil
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
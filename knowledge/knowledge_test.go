@@ -0,0 +1,175 @@
+package knowledge
+
+import (
+	"ai-agent-api-discovery/models"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeKeepsOnlyAuthOfFailedRuns(t *testing.T) {
+	h := &Host{Host: "localhost:8081"}
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	h.merge(Run{
+		Endpoint:    "POST /api/users",
+		Fields:      []models.FieldInfo{{Name: "email", Type: "string", SampleValue: "a@example.com"}},
+		MinimalBody: map[string]interface{}{"email": "a@example.com"},
+		Headers:     map[string]string{"X-Api-Version": "2"},
+		Dialects:    map[string]int{"drf": 2},
+	}, now)
+	h.merge(Run{
+		Failed:      true,
+		Endpoint:    "POST /api/orders",
+		Fields:      []models.FieldInfo{{Name: "sku", Type: "string", SampleValue: "X1"}},
+		MinimalBody: map[string]interface{}{"sku": "X1"},
+		Headers:     map[string]string{"X-Trace": "1"},
+		AuthHeaders: []string{"Authorization"},
+		Challenged:  true,
+		Dialects:    map[string]int{"drf": 1, "plain": 1},
+	}, now.Add(time.Hour))
+
+	if h.Runs != 1 || h.FailedRuns != 1 || !h.Updated.Equal(now.Add(time.Hour)) {
+		t.Errorf("runs = %d, failed = %d, updated = %v", h.Runs, h.FailedRuns, h.Updated)
+	}
+	if _, ok := h.Fields["sku"]; ok {
+		t.Error("a failed run's field was recorded")
+	}
+	if _, ok := h.Endpoints["POST /api/orders"]; ok {
+		t.Error("a failed run's body was recorded")
+	}
+	if !reflect.DeepEqual(h.Headers, map[string]string{"X-Api-Version": "2"}) {
+		t.Errorf("headers = %v", h.Headers)
+	}
+	wantAuth := Auth{Headers: []string{"Authorization"}, Challenged: true}
+	if !reflect.DeepEqual(h.Auth, wantAuth) {
+		t.Errorf("auth = %+v, want %+v", h.Auth, wantAuth)
+	}
+	if h.Dialect() != "drf" || h.Dialects["plain"] != 1 {
+		t.Errorf("dialects = %v", h.Dialects)
+	}
+	if got := h.Fields["email"].Values; !reflect.DeepEqual(got, []interface{}{"a@example.com"}) {
+		t.Errorf("email values = %v", got)
+	}
+}
+
+func TestAddValueKeepsNewestDistinctValues(t *testing.T) {
+	f := &Field{}
+	for _, v := range []interface{}{"a", "b", "a", 1.0, "c", "d", "e", "f"} {
+		f.addValue(v)
+	}
+	want := []interface{}{"f", "e", "d", "c", 1.0}
+	if !reflect.DeepEqual(f.Values, want) {
+		t.Errorf("values = %v, want %v", f.Values, want)
+	}
+
+	// Values that encode alike are the same value, whatever their Go type
+	f = &Field{Values: []interface{}{float64(2), map[string]interface{}{"x": 1.0}}}
+	f.addValue(map[string]interface{}{"x": 1})
+	if want := []interface{}{map[string]interface{}{"x": 1}, float64(2)}; !reflect.DeepEqual(f.Values, want) {
+		t.Errorf("values = %v, want %v", f.Values, want)
+	}
+}
+
+func TestValidHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost:8081":       true,
+		"api.example.com":      true,
+		"10.0.0.1":             true,
+		"":                     false,
+		"..":                   false,
+		"../etc/passwd":        false,
+		"a/../../b":            false,
+		`host\..\other`:        false,
+		"-flag":                false,
+		"host:port":            false,
+		"host:80/../x":         false,
+		"[::1]:8080":           false,
+		"example.com\x00.json": false,
+	} {
+		if got := ValidHost(host); got != want {
+			t.Errorf("ValidHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+// openTemp opens a knowledge base in a temporary directory for one test
+func openTemp(t *testing.T) string {
+	t.Helper()
+	saved := dir
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		dir = saved
+	})
+	path := t.TempDir()
+	if err := Open(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFilePathStaysInDirectory(t *testing.T) {
+	path := openTemp(t)
+	got, err := filePath("LocalHost:8081")
+	if want := filepath.Join(path, "localhost_8081.json"); err != nil || got != want {
+		t.Errorf("filePath = %q, %v; want %q", got, err, want)
+	}
+	for _, host := range []string{"../outside", "..", "a/b"} {
+		if got, err := filePath(host); err == nil {
+			t.Errorf("filePath(%q) = %q, want an error", host, got)
+		}
+	}
+}
+
+func TestDialectTieBreaksByName(t *testing.T) {
+	tests := []struct {
+		dialects map[string]int
+		want     string
+	}{
+		{nil, ""},
+		{map[string]int{"rails": 3, "drf": 1}, "rails"},
+		{map[string]int{"rails": 2, "drf": 2, "spring": 2}, "drf"},
+		{map[string]int{"spring": 2, "express": 1, "drf": 2}, "drf"},
+	}
+	for _, tt := range tests {
+		// Map order varies between runs, so the tie must not depend on it
+		for i := 0; i < 10; i++ {
+			if got := (&Host{Dialects: tt.dialects}).Dialect(); got != tt.want {
+				t.Errorf("Dialect(%v) = %q, want %q", tt.dialects, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRecordGetDelete(t *testing.T) {
+	openTemp(t)
+	run := Run{
+		Endpoint: "POST /api/users",
+		Fields:   []models.FieldInfo{{Name: "email", Type: "string", SampleValue: "a@example.com"}},
+	}
+	if err := Record("localhost:8081", run); err != nil {
+		t.Fatal(err)
+	}
+	h, err := Get("localhost:8081")
+	if err != nil || h == nil || h.Runs != 1 || h.Fields["email"] == nil {
+		t.Fatalf("Get = %+v, %v", h, err)
+	}
+	if summaries, err := List(); err != nil || len(summaries) != 1 || summaries[0].Host != "localhost:8081" {
+		t.Errorf("List = %+v, %v", summaries, err)
+	}
+
+	if deleted, err := Delete("localhost:8081"); !deleted || err != nil {
+		t.Errorf("Delete = %v, %v", deleted, err)
+	}
+	if h, err := Get("localhost:8081"); h != nil || err != nil {
+		t.Errorf("Get after Delete = %+v, %v", h, err)
+	}
+	if deleted, err := Delete("localhost:8081"); deleted || err != nil {
+		t.Errorf("second Delete = %v, %v", deleted, err)
+	}
+	if _, err := Delete("../localhost:8081"); err == nil {
+		t.Error("deleted a host outside the knowledge directory")
+	}
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"ai-agent-api-discovery/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidAction is wrapped by every error ParseAction returns, so callers
+// can tell a reply that needs repair from a failed LLM call
+var ErrInvalidAction = errors.New("invalid action")
+
+// thinkRegex matches reasoning blocks some models put before their answer
+var thinkRegex = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// fenceRegex matches fenced code blocks, with or without a language tag
+var fenceRegex = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n?(.*?)```")
+
+// ParseAction extracts the action from an LLM reply. Reasoning blocks are
+// dropped and fenced code is preferred: the first valid action in a fenced
+// block wins, and bare JSON in the prose is only considered when no fenced
+// block holds a valid action, in which case the last valid one wins. The
+// error explains what was wrong, in terms suitable for sending back to the model.
+func (c *DeepseekClient) ParseAction(content string) (Action, error) {
+	utils.Logger.Printf("Parsing action from content:\n%s", content)
+
+	text := thinkRegex.ReplaceAllString(content, "")
+	if i := strings.Index(text, "<think>"); i >= 0 {
+		// Unterminated reasoning block: only what precedes it can be the answer
+		text = text[:i]
+	}
+
+	var fenced []string
+	for _, match := range fenceRegex.FindAllStringSubmatch(text, -1) {
+		fenced = append(fenced, jsonObjects(match[1])...)
+	}
+	prose := jsonObjects(fenceRegex.ReplaceAllString(text, ""))
+	if len(fenced) == 0 && len(prose) == 0 {
+		utils.Logger.Printf("No JSON object found in content")
+		return nil, fmt.Errorf("%w: no JSON object found in the reply", ErrInvalidAction)
+	}
+
+	var firstErr error
+	var result Action
+	for _, candidate := range fenced {
+		action, err := validateAction(candidate)
+		if err == nil {
+			result = action
+			break
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if result == nil {
+		for _, candidate := range prose {
+			action, err := validateAction(candidate)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			result = action
+		}
+	}
+	if result == nil {
+		utils.Logger.Printf("No valid action found: %v", firstErr)
+		return nil, firstErr
+	}
+
+	utils.Logger.Printf("Successfully parsed action:\n%s", EncodeAction(result))
+	return result, nil
+}
+
+// validateAction decodes a {"action": name, ...arguments} object into its
+// typed action
+func validateAction(candidate string) (Action, error) {
+	var envelope struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal([]byte(candidate), &envelope); err != nil {
+		return nil, fmt.Errorf("%w: the JSON is malformed: %v", ErrInvalidAction, err)
+	}
+	return decodeAction(envelope.Action, []byte(candidate))
+}
+
+// jsonObjects returns every top-level {...} span in text, matching braces
+// outside of JSON strings
+func jsonObjects(text string) []string {
+	var objects []string
+	depth, start := 0, -1
+	inString, escaped := false, false
+
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			if depth > 0 {
+				inString = true
+			}
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				objects = append(objects, text[start:i+1])
+			}
+		}
+	}
+	return objects
+}
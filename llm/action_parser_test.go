@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"ai-agent-api-discovery/utils"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func init() {
+	utils.Logger = log.New(io.Discard, "", 0)
+}
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Action
+	}{
+		{
+			name:    "bare object",
+			content: `{"action": "modify_fields", "body": {"email": "a@example.com"}}`,
+			want:    &ModifyFields{Body: map[string]interface{}{"email": "a@example.com"}},
+		},
+		{
+			name: "fenced block wins over prose that mentions another action",
+			content: "I will add the email.\n```json\n{\"action\": \"modify_fields\", \"body\": {\"email\": \"a@example.com\"}}\n```\n" +
+				`Once that works I will reply {"action": "complete"}.`,
+			want: &ModifyFields{Body: map[string]interface{}{"email": "a@example.com"}},
+		},
+		{
+			name: "first valid fenced block wins",
+			content: "```json\n{\"action\": \"remove_field\", \"fields\": [\"age\"]}\n```\nor\n" +
+				"```json\n{\"action\": \"complete\"}\n```",
+			want: &RemoveField{Fields: []string{"age"}},
+		},
+		{
+			name:    "invalid fenced block falls back to prose",
+			content: "```json\n{\"action\": \"modify_fields\"}\n```\nSorry, I meant {\"action\": \"ask_for_state\"}",
+			want:    &AskForState{},
+		},
+		{
+			name:    "last valid object in prose wins",
+			content: `First {"action": "complete"} but actually {"action": "remove_field", "fields": ["name"]}`,
+			want:    &RemoveField{Fields: []string{"name"}},
+		},
+		{
+			name:    "trailing prose after the object",
+			content: "{\"action\": \"set_header\", \"name\": \"X-Api-Version\", \"value\": \"2\"}\nThis sets the version header {as required}.",
+			want:    &SetHeader{Name: "X-Api-Version", Value: "2"},
+		},
+		{
+			name:    "braces inside strings",
+			content: `{"action": "modify_fields", "body": {"note": "a } b {"}}`,
+			want:    &ModifyFields{Body: map[string]interface{}{"note": "a } b {"}},
+		},
+		{
+			name:    "reasoning block dropped",
+			content: `<think>maybe {"action": "complete"}</think>{"action": "ask_for_state"}`,
+			want:    &AskForState{},
+		},
+	}
+
+	c := &DeepseekClient{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.ParseAction(tt.content)
+			if err != nil {
+				t.Fatalf("ParseAction: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %s, want %s", EncodeAction(got), EncodeAction(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseActionErrors(t *testing.T) {
+	tests := map[string]string{
+		"no JSON":            "I am not sure what to do next.",
+		"unknown action":     `{"action": "dance"}`,
+		"missing arguments":  `{"action": "modify_fields"}`,
+		"unterminated think": `<think>{"action": "complete"}`,
+	}
+	c := &DeepseekClient{}
+	for name, content := range tests {
+		if _, err := c.ParseAction(content); !errors.Is(err, ErrInvalidAction) {
+			t.Errorf("%s: err = %v, want ErrInvalidAction", name, err)
+		}
+	}
+}
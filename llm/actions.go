@@ -0,0 +1,387 @@
+package llm
+
+import (
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Names of the actions the agent understands
+const (
+	ActionModifyFields        = "modify_fields"
+	ActionSendRequest         = "send_request"
+	ActionRemoveField         = "remove_field"
+	ActionSetHeader           = "set_header"
+	ActionTryArrayBody        = "try_array_body"
+	ActionInspectLastResponse = "inspect_last_response"
+	ActionMarkFieldOptional   = "mark_field_optional"
+	ActionAskForState         = "ask_for_state"
+	ActionComplete            = "complete"
+)
+
+// Action is an operation the LLM asks the agent to perform. Each action is a
+// struct whose exported fields are its arguments; the struct tags double as
+// the tool schema sent to models that support tool calling.
+type Action interface {
+	// ActionName is the name the model uses for the action
+	ActionName() string
+	// Validate reports what is wrong with the arguments, in terms the model can act on
+	Validate() error
+}
+
+// ModifyFields sets fields on the current body and sends it
+type ModifyFields struct {
+	Body        map[string]interface{} `json:"body" description:"Fields to set on the current request body; fields not listed are kept"`
+	Explanation string                 `json:"explanation,omitempty" description:"Reasoning behind these changes"`
+}
+
+func (*ModifyFields) ActionName() string { return ActionModifyFields }
+
+func (a *ModifyFields) Validate() error {
+	if a.Body == nil {
+		return fmt.Errorf("missing \"body\" field")
+	}
+	return nil
+}
+
+// SendRequest replaces the current body and sends it as given
+type SendRequest struct {
+	Body        map[string]interface{} `json:"body" description:"The complete JSON request body to send"`
+	Explanation string                 `json:"explanation,omitempty" description:"What this request tests"`
+}
+
+func (*SendRequest) ActionName() string { return ActionSendRequest }
+
+func (a *SendRequest) Validate() error {
+	if a.Body == nil {
+		return fmt.Errorf("missing \"body\" field")
+	}
+	return nil
+}
+
+// RemoveField removes fields from the current body and sends it
+type RemoveField struct {
+	Fields      []string `json:"fields" description:"Names of the top-level fields to remove"`
+	Explanation string   `json:"explanation,omitempty" description:"What removing these fields tests"`
+}
+
+func (*RemoveField) ActionName() string { return ActionRemoveField }
+
+func (a *RemoveField) Validate() error {
+	if len(a.Fields) == 0 {
+		return fmt.Errorf("\"fields\" must list at least one field")
+	}
+	return nil
+}
+
+// SetHeader sets or clears a header for all later requests
+type SetHeader struct {
+	Name        string `json:"name" description:"Header name, e.g. Content-Type"`
+	Value       string `json:"value" description:"Header value; empty removes the header"`
+	Explanation string `json:"explanation,omitempty" description:"Why the header is needed"`
+}
+
+func (*SetHeader) ActionName() string { return ActionSetHeader }
+
+func (a *SetHeader) Validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("missing \"name\" field")
+	}
+	return nil
+}
+
+// TryArrayBody sends the current body as a one-item array
+type TryArrayBody struct {
+	Key         string `json:"key,omitempty" description:"If set, send {key: [body]} instead of [body]"`
+	Explanation string `json:"explanation,omitempty" description:"Why an array body may be expected"`
+}
+
+func (*TryArrayBody) ActionName() string { return ActionTryArrayBody }
+
+func (*TryArrayBody) Validate() error { return nil }
+
+// InspectLastResponse shows the full last response, headers included
+type InspectLastResponse struct {
+	Explanation string `json:"explanation,omitempty" description:"What to look for"`
+}
+
+func (*InspectLastResponse) ActionName() string { return ActionInspectLastResponse }
+
+func (*InspectLastResponse) Validate() error { return nil }
+
+// MarkFieldOptional records that a field is not required
+type MarkFieldOptional struct {
+	Field  string `json:"field" description:"Name of the field"`
+	Reason string `json:"reason,omitempty" description:"Evidence that the field is optional"`
+}
+
+func (*MarkFieldOptional) ActionName() string { return ActionMarkFieldOptional }
+
+func (a *MarkFieldOptional) Validate() error {
+	if a.Field == "" {
+		return fmt.Errorf("missing \"field\" field")
+	}
+	return nil
+}
+
+// AskForState shows what the agent currently knows
+type AskForState struct{}
+
+func (*AskForState) ActionName() string { return ActionAskForState }
+
+func (*AskForState) Validate() error { return nil }
+
+// Complete ends discovery
+type Complete struct {
+	Explanation string `json:"explanation,omitempty" description:"Why discovery is complete"`
+}
+
+func (*Complete) ActionName() string { return ActionComplete }
+
+func (*Complete) Validate() error { return nil }
+
+// actionSpec describes one action for parsing and for the tool schema
+type actionSpec struct {
+	name        string
+	description string
+	new         func() Action
+}
+
+// actionSpecs lists every action the agent understands, in the order they
+// are offered to the model
+var actionSpecs = []actionSpec{
+	{ActionModifyFields, "Set fields on the current request body and send it", func() Action { return &ModifyFields{} }},
+	{ActionSendRequest, "Send exactly this request body, replacing the current one", func() Action { return &SendRequest{} }},
+	{ActionRemoveField, "Remove fields from the current request body and send it", func() Action { return &RemoveField{} }},
+	{ActionSetHeader, "Set or clear a request header for all later requests; sends nothing", func() Action { return &SetHeader{} }},
+	{ActionTryArrayBody, "Send the current request body wrapped in a one-item array", func() Action { return &TryArrayBody{} }},
+	{ActionInspectLastResponse, "Show the full last response including headers; sends nothing", func() Action { return &InspectLastResponse{} }},
+	{ActionMarkFieldOptional, "Record that a field is optional; sends nothing", func() Action { return &MarkFieldOptional{} }},
+	{ActionAskForState, "Show the known fields, current body and headers; sends nothing", func() Action { return &AskForState{} }},
+	{ActionComplete, "Finish discovery once the required fields are known", func() Action { return &Complete{} }},
+}
+
+// actionNames returns the names of all actions, quoted, for error messages
+func actionNames() string {
+	names := make([]string, len(actionSpecs))
+	for i, spec := range actionSpecs {
+		names[i] = fmt.Sprintf("%q", spec.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// decodeAction decodes the arguments of a named action and validates them
+func decodeAction(name string, args []byte) (Action, error) {
+	for _, spec := range actionSpecs {
+		if spec.name != name {
+			continue
+		}
+		action := spec.new()
+		if len(args) > 0 {
+			if err := json.Unmarshal(args, action); err != nil {
+				return nil, fmt.Errorf("%w: arguments of %q do not match its schema: %v", ErrInvalidAction, name, err)
+			}
+		}
+		if err := action.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAction, err)
+		}
+		return action, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: missing \"action\" field", ErrInvalidAction)
+	}
+	return nil, fmt.Errorf("%w: unknown action %q, expected one of %s", ErrInvalidAction, name, actionNames())
+}
+
+// EncodeAction renders an action in the text format of the system prompt,
+// {"action": name, ...arguments}, for logs and the conversation history
+func EncodeAction(action Action) string {
+	fields := map[string]interface{}{}
+	if data, err := json.Marshal(action); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	fields["action"] = action.ActionName()
+	data, _ := json.MarshalIndent(fields, "", "  ")
+	return string(data)
+}
+
+// Tool is a function definition in the OpenAI-compatible tool calling format
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction describes a callable function and its JSON Schema parameters
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolCall is a function call returned by the model
+type ToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// actionTools builds the tool definitions for every action from its struct
+func actionTools() []Tool {
+	tools := make([]Tool, len(actionSpecs))
+	for i, spec := range actionSpecs {
+		tools[i] = Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        spec.name,
+				Description: spec.description,
+				Parameters:  schemaFor(reflect.TypeOf(spec.new()).Elem()),
+			},
+		}
+	}
+	return tools
+}
+
+// schemaFor derives a JSON Schema from a Go type, using json tags for names,
+// omitempty for optional properties and description tags for descriptions
+func schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := schemaFor(field.Type)
+			if description := field.Tag.Get("description"); description != "" {
+				property["description"] = description
+			}
+			properties[name] = property
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "required": required}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// Ways of asking a model for an action
+const (
+	ActionModeAuto  = "auto"  // the best mode the model supports
+	ActionModeTools = "tools" // native tool calling
+	ActionModeJSON  = "json"  // native JSON output, parsed as text
+	ActionModeText  = "text"  // free text, parsed leniently
+)
+
+// modelCapabilities records what Deepseek models support. Models of other
+// providers are assumed to support both tool calling and JSON output.
+var modelCapabilities = map[ModelType]struct{ tools, json bool }{
+	ModelChat: {tools: true, json: true},
+	ModelR1:   {tools: false, json: false},
+}
+
+var actionMode = ActionModeAuto
+
+// SetActionMode sets how models are asked for actions. Models that do not
+// support the chosen mode fall back to the next simpler one.
+func SetActionMode(mode string) error {
+	switch mode {
+	case ActionModeAuto, ActionModeTools, ActionModeJSON, ActionModeText:
+	default:
+		return fmt.Errorf("unknown action mode %q", mode)
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	actionMode = mode
+	return nil
+}
+
+// requestOptions are the optional parts of a chat completion request
+type requestOptions struct {
+	jsonMode    bool
+	tools       []Tool
+	temperature *float64 // nil uses the model's default, as does a maxTokens of 0
+	maxTokens   int
+	seed        *int
+}
+
+// actionOptions returns the request options for asking a model for an action
+func actionOptions(model ModelType) requestOptions {
+	configMu.RLock()
+	mode := actionMode
+	configMu.RUnlock()
+
+	caps, known := modelCapabilities[model]
+	if !known {
+		caps.tools, caps.json = true, true
+	}
+	switch {
+	case (mode == ActionModeAuto || mode == ActionModeTools) && caps.tools:
+		return requestOptions{tools: actionTools()}
+	case mode != ActionModeText && caps.json:
+		return requestOptions{jsonMode: true}
+	default:
+		return requestOptions{}
+	}
+}
+
+// CompleteAction asks the run's action model for the next action. Tool calls are decoded
+// directly, and only the first is run; otherwise the reply text is parsed. The returned message is what
+// belongs in the conversation history: the reply text, or the tool call
+// rendered in the text format so the history reads the same for every mode.
+func (c *DeepseekClient) CompleteAction(messages []models.Message) (Action, *models.Message, error) {
+	result, err := c.complete(messages, c.actionSettings().Action, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(result.ToolCalls) == 0 {
+		action, err := c.ParseAction(result.Message.Content)
+		return action, &result.Message, err
+	}
+
+	call := result.ToolCalls[0]
+	for _, dropped := range result.ToolCalls[1:] {
+		utils.Logger.Printf("Ignoring extra tool call %s %s; only one action is run per step", dropped.Function.Name, dropped.Function.Arguments)
+	}
+	message := &models.Message{Role: "assistant", Content: call.Function.Name + " " + call.Function.Arguments, Reasoning: result.Message.Reasoning}
+	action, err := decodeAction(call.Function.Name, []byte(call.Function.Arguments))
+	if err != nil {
+		return nil, message, err
+	}
+	message.Content = EncodeAction(action)
+	utils.Logger.Printf("Decoded tool call:\n%s", message.Content)
+	return action, message, nil
+}
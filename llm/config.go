@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"ai-agent-api-discovery/models"
+	"fmt"
+	"strings"
+)
+
+// maxTemperature is the highest temperature OpenAI-compatible APIs accept
+const maxTemperature = 2.0
+
+// Settings is the LLM configuration of one discovery run
+type Settings struct {
+	Action      ChainEntry // model asked for actions; the fallback chain follows it
+	Temperature *float64   // nil uses the model's default
+	MaxTokens   int        // 0 uses the model's default
+	Seed        *int
+}
+
+var (
+	// defaultAction is the model asked for actions when a request names none
+	defaultAction = ChainEntry{Provider: defaultProvider, Model: ModelR1}
+	// maxTokensLimit caps the completion tokens a request may ask for
+	maxTokensLimit = 8000
+	// allowedModels lists the "provider/model" entries requests may choose; nil allows any
+	allowedModels map[string]bool
+)
+
+// SetDefaultModel sets the model asked for actions when a request names
+// none, as "model" (a Deepseek model) or "provider/model". Register the
+// provider first.
+func SetDefaultModel(spec string) error {
+	chain, err := ParseChain(spec)
+	if err != nil {
+		return err
+	}
+	if len(chain) != 1 {
+		return fmt.Errorf("default model must be a single model, got %q", spec)
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	if _, known := providers[chain[0].Provider]; !known && chain[0].Provider != defaultProvider {
+		return fmt.Errorf("unknown provider %q in default model", chain[0].Provider)
+	}
+	defaultAction = chain[0]
+	return nil
+}
+
+// SetLimits bounds what requests may ask for: maxTokens caps completion
+// tokens per call, and allowed, if not empty, is a comma-separated list of
+// the only models requests may choose
+func SetLimits(maxTokens int, allowed string) error {
+	chain, err := ParseChain(allowed)
+	if err != nil {
+		return err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	maxTokensLimit = maxTokens
+	allowedModels = nil
+	if len(chain) > 0 {
+		allowedModels = make(map[string]bool, len(chain))
+		for _, entry := range chain {
+			allowedModels[entry.String()] = true
+		}
+	}
+	return nil
+}
+
+// ResolveSettings applies the server defaults to a request's LLM block and
+// checks it against the server limits
+func ResolveSettings(cfg models.LLMConfig) (Settings, error) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	settings := Settings{Action: defaultAction, Temperature: cfg.Temperature, MaxTokens: cfg.MaxTokens, Seed: cfg.Seed}
+	if cfg.Model != "" {
+		settings.Action = ChainEntry{Provider: defaultProvider, Model: ModelType(cfg.Model)}
+	}
+	if cfg.Provider != "" {
+		settings.Action.Provider = strings.ToLower(cfg.Provider)
+		if cfg.Model == "" && settings.Action.Provider != defaultAction.Provider {
+			return Settings{}, fmt.Errorf("llm.model is required when llm.provider is %q", cfg.Provider)
+		}
+	}
+
+	if _, known := providers[settings.Action.Provider]; !known && settings.Action.Provider != defaultProvider {
+		return Settings{}, fmt.Errorf("unknown LLM provider %q", settings.Action.Provider)
+	}
+	if allowedModels != nil && !allowedModels[settings.Action.String()] && settings.Action != defaultAction {
+		return Settings{}, fmt.Errorf("LLM model %s is not allowed on this server", settings.Action)
+	}
+	if t := cfg.Temperature; t != nil && (*t < 0 || *t > maxTemperature) {
+		return Settings{}, fmt.Errorf("llm.temperature must be between 0 and %g", maxTemperature)
+	}
+	if cfg.MaxTokens < 0 || (maxTokensLimit > 0 && cfg.MaxTokens > maxTokensLimit) {
+		return Settings{}, fmt.Errorf("llm.maxTokens must be between 1 and %d", maxTokensLimit)
+	}
+	return settings, nil
+}
+
+// Configure sets the model and sampling the client uses for actions
+func (c *DeepseekClient) Configure(settings Settings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settings = settings
+}
+
+// actionSettings returns the client's settings for actions
+func (c *DeepseekClient) actionSettings() Settings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
+
+// defaultSampling returns the temperature and completion tokens a model is
+// called with unless the run says otherwise
+func defaultSampling(model ModelType) (float64, int) {
+	if model == ModelR1 {
+		// Lower temperature for more focused reasoning, more tokens for the reasoning itself
+		return 0.3, 2000
+	}
+	return 0.7, 1000
+}
@@ -4,12 +4,16 @@ import (
 	"ai-agent-api-discovery/models"
 	"ai-agent-api-discovery/utils"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ModelType represents different DeepSeek model types
@@ -25,6 +29,12 @@ type DeepseekClient struct {
 	apiKey     string
 	apiBaseURL string
 	client     *http.Client
+
+	mu       sync.Mutex
+	ctx      context.Context // cancels calls when the run is abandoned
+	usage    models.LLMUsage
+	budget   models.LLMBudget
+	settings Settings
 }
 
 // DeepseekRequest represents a request to the Deepseek API
@@ -33,17 +43,29 @@ type DeepseekRequest struct {
 	Messages    []models.Message `json:"messages"`
 	Temperature float64          `json:"temperature"`
 	MaxTokens   int              `json:"max_tokens"`
+	Seed        *int             `json:"seed,omitempty"`
 	Stream      bool             `json:"stream"`
+	// StreamOptions is only set when streaming, see SetStreamReasoning
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	// ResponseFormat asks for native JSON output, e.g. {"type": "json_object"}
+	ResponseFormat map[string]string `json:"response_format,omitempty"`
+	Tools          []Tool            `json:"tools,omitempty"`
+	ToolChoice     string            `json:"tool_choice,omitempty"`
+	// ParallelToolCalls is set to false with tools, since one action is run per step
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
 }
 
 // DeepseekResponse represents a response from the Deepseek API
 type DeepseekResponse struct {
 	Choices []struct {
 		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
+			Role             string     `json:"role"`
+			Content          string     `json:"content"`
+			ReasoningContent string     `json:"reasoning_content,omitempty"` // deepseek-reasoner only
+			ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
@@ -56,16 +78,120 @@ func NewDeepseekClient() (*DeepseekClient, error) {
 		return nil, fmt.Errorf("DEEPSEEK_API_KEY environment variable is not set")
 	}
 
+	configMu.RLock()
+	settings := Settings{Action: defaultAction}
+	configMu.RUnlock()
+
 	return &DeepseekClient{
 		apiKey:     apiKey,
-		apiBaseURL: "https://api.deepseek.com", // No /v1 needed per docs
+		apiBaseURL: deepseekBaseURL,
 		client:     &http.Client{},
+		ctx:        context.Background(),
+		settings:   settings,
 	}, nil
 }
 
-// CompleteWithModel sends a completion request to the Deepseek API using the specified model
+// SetContext makes later calls stop, without retries or fallbacks, once ctx
+// is done
+func (c *DeepseekClient) SetContext(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ctx = ctx
+}
+
+// context returns the context calls are made in
+func (c *DeepseekClient) context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx
+}
+
+// CompleteWithModel sends a completion request using the specified model.
+// Rate limits, server errors and malformed replies are retried with backoff;
+// if the model still fails, the configured fallback chain is tried in order.
+// The returned error wraps ErrAuth, ErrQuota, ErrTransient or ErrRequest, or
+// ErrBudget if the run's budget does not allow another call.
 func (c *DeepseekClient) CompleteWithModel(messages []models.Message, model ModelType) (*models.Message, error) {
-	utils.Logger.Printf("Sending completion request to Deepseek API with %d messages using model %s", len(messages), model)
+	result, err := c.complete(messages, ChainEntry{Provider: defaultProvider, Model: model}, false)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Message, nil
+}
+
+// completion is a model reply: its message, any tool calls and what it cost
+type completion struct {
+	Message   models.Message
+	ToolCalls []ToolCall
+	Usage     Usage
+}
+
+// complete runs a completion through the fallback chain. For actions, each
+// model is asked for tool calls or native JSON output when it supports them,
+// and sampled as the run's settings say.
+func (c *DeepseekClient) complete(messages []models.Message, primary ChainEntry, forAction bool) (*completion, error) {
+	utils.Logger.Printf("Sending completion request with %d messages using model %s", len(messages), primary)
+	if err := c.checkBudget(); err != nil {
+		return nil, err
+	}
+
+	chain := chainFor(primary)
+	settings := c.actionSettings()
+	var lastErr error
+	for i, entry := range chain {
+		var opts requestOptions
+		if forAction {
+			opts = actionOptions(entry.Model)
+			opts.temperature, opts.maxTokens, opts.seed = settings.Temperature, settings.MaxTokens, settings.Seed
+		}
+		response, err := c.completeWithRetry(messages, entry, opts)
+		if err == nil {
+			return response, nil
+		}
+		if ctxErr := c.context().Err(); ctxErr != nil {
+			return nil, fmt.Errorf("completion abandoned: %w", ctxErr)
+		}
+		lastErr = err
+		if i < len(chain)-1 {
+			utils.Logger.Printf("Model %s failed (%v), falling back to %s", entry, err, chain[i+1])
+		}
+	}
+	return nil, lastErr
+}
+
+// completeWithRetry calls one model, retrying transient failures. Every
+// attempt is recorded in the usage, failed ones included.
+func (c *DeepseekClient) completeWithRetry(messages []models.Message, entry ChainEntry, opts requestOptions) (*completion, error) {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		response, err := c.send(messages, entry, opts)
+		if err == nil {
+			c.recordCall(entry, start, response.Usage, nil)
+			return response, nil
+		}
+		c.recordCall(entry, start, Usage{}, err)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !errors.Is(err, ErrTransient) || attempt >= maxLLMAttempts {
+			return nil, err
+		}
+		delay := llmBackoff(attempt, apiErr)
+		utils.Logger.Printf("Model %s attempt %d failed (%v), retrying in %v", entry, attempt, err, delay)
+		if err := utils.Sleep(c.context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send makes a single chat completion call to one model of one provider
+func (c *DeepseekClient) send(messages []models.Message, entry ChainEntry, opts requestOptions) (*completion, error) {
+	provider, ok := c.provider(entry.Provider)
+	if !ok {
+		return nil, &APIError{Provider: entry.Provider, Model: entry.Model, Kind: ErrRequest, Message: "provider is not registered"}
+	}
+	model := entry.Model
+	fail := func(status int, kind error, format string, args ...interface{}) *APIError {
+		return &APIError{Provider: provider.Name, Model: model, StatusCode: status, Kind: kind, Message: fmt.Sprintf(format, args...)}
+	}
 
 	// For the reasoner model, we need special message ordering
 	if model == ModelR1 {
@@ -133,17 +259,30 @@ func (c *DeepseekClient) CompleteWithModel(messages []models.Message, model Mode
 	}
 
 	reqBody := DeepseekRequest{
-		Model:       string(model),
-		Messages:    messages,
-		Temperature: 0.7,
-		MaxTokens:   1000,
-		Stream:      false,
+		Model:    string(model),
+		Messages: messages,
+		Seed:     opts.seed,
+		Stream:   false,
 	}
-
-	// Adjust parameters for R1 model
-	if model == ModelR1 {
-		reqBody.Temperature = 0.3 // Lower temperature for more focused reasoning
-		reqBody.MaxTokens = 2000  // Increased context for complex reasoning
+	reqBody.Temperature, reqBody.MaxTokens = defaultSampling(model)
+	if opts.temperature != nil {
+		reqBody.Temperature = *opts.temperature
+	}
+	if opts.maxTokens > 0 {
+		reqBody.MaxTokens = opts.maxTokens
+	}
+	if opts.jsonMode {
+		reqBody.ResponseFormat = map[string]string{"type": "json_object"}
+	}
+	if len(opts.tools) > 0 {
+		reqBody.Tools = opts.tools
+		reqBody.ToolChoice = "required"
+		parallel := false
+		reqBody.ParallelToolCalls = &parallel
+	}
+	if streaming(opts) {
+		reqBody.Stream = true
+		reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -153,47 +292,75 @@ func (c *DeepseekClient) CompleteWithModel(messages []models.Message, model Mode
 
 	utils.Logger.Printf("Request body:\n%s", string(jsonData))
 
-	req, err := http.NewRequest("POST", c.apiBaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(c.context(), "POST", provider.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", provider.APIKey))
 
 	utils.Logger.Printf("Sending request to %s", req.URL)
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fail(0, ErrTransient, "failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if reqBody.Stream && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		response, err := readStream(resp.Body)
+		if err != nil {
+			return nil, fail(resp.StatusCode, ErrTransient, "%v", err)
+		}
+		return response, nil
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fail(resp.StatusCode, ErrTransient, "failed to read response body: %v", err)
 	}
 
 	utils.Logger.Printf("Raw response from API:\n%s", string(body))
 
 	var deepseekResp DeepseekResponse
-	if err := json.Unmarshal(body, &deepseekResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	unmarshalErr := json.Unmarshal(body, &deepseekResp)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := strings.TrimSpace(string(body))
+		if unmarshalErr == nil && deepseekResp.Error != nil {
+			message = deepseekResp.Error.Message
+		}
+		utils.Logger.Printf("API returned status %d: %s", resp.StatusCode, message)
+		apiErr := fail(resp.StatusCode, classifyStatus(resp.StatusCode, message), "%s", message)
+		apiErr.retryAfter = parseRetryAfter(resp.Header)
+		return nil, apiErr
+	}
+
+	if unmarshalErr != nil {
+		return nil, fail(resp.StatusCode, ErrTransient, "failed to unmarshal response: %v", unmarshalErr)
 	}
 
 	if deepseekResp.Error != nil {
 		utils.Logger.Printf("API returned error: %s", deepseekResp.Error.Message)
-		return nil, fmt.Errorf("Deepseek API error: %s", deepseekResp.Error.Message)
+		return nil, fail(resp.StatusCode, classifyStatus(resp.StatusCode, deepseekResp.Error.Message), "%s", deepseekResp.Error.Message)
 	}
 
 	if len(deepseekResp.Choices) == 0 {
-		return nil, fmt.Errorf("no completion choices returned")
+		return nil, fail(resp.StatusCode, ErrTransient, "no completion choices returned")
 	}
 
-	response := &models.Message{
-		Role:    deepseekResp.Choices[0].Message.Role,
-		Content: deepseekResp.Choices[0].Message.Content,
+	choice := deepseekResp.Choices[0].Message
+	response := &completion{
+		Message:   models.Message{Role: choice.Role, Content: choice.Content, Reasoning: choice.ReasoningContent},
+		ToolCalls: choice.ToolCalls,
 	}
-	utils.Logger.Printf("Parsed response:\nRole: %s\nContent: %s", response.Role, response.Content)
+	if deepseekResp.Usage != nil {
+		response.Usage = *deepseekResp.Usage
+	}
+	if choice.ReasoningContent != "" {
+		utils.Logger.Printf("Reasoning:\n%s", choice.ReasoningContent)
+	}
+	utils.Logger.Printf("Parsed response:\nRole: %s\nContent: %s\nTool calls: %d", choice.Role, choice.Content, len(choice.ToolCalls))
 
 	return response, nil
 }
@@ -207,39 +374,3 @@ func (c *DeepseekClient) Complete(messages []models.Message) (*models.Message, e
 func (c *DeepseekClient) CompleteWithR1(messages []models.Message) (*models.Message, error) {
 	return c.CompleteWithModel(messages, ModelR1)
 }
-
-// ParseAction parses the LLM response into an action map
-func (c *DeepseekClient) ParseAction(content string) (map[string]interface{}, error) {
-	utils.Logger.Printf("Parsing action from content:\n%s", content)
-
-	// Find the first { and last } to extract JSON
-	start := strings.Index(content, "{")
-	end := strings.LastIndex(content, "}")
-
-	if start == -1 || end == -1 || end <= start {
-		utils.Logger.Printf("No valid JSON found in content")
-		return nil, fmt.Errorf("no valid JSON found in content: %s", content)
-	}
-
-	jsonStr := content[start : end+1]
-	utils.Logger.Printf("Extracted JSON:\n%s", jsonStr)
-
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-		utils.Logger.Printf("Failed to parse JSON: %v", err)
-		return nil, fmt.Errorf("failed to parse action: %w", err)
-	}
-
-	// Validate required fields
-	if result["action"] == nil {
-		utils.Logger.Printf("Missing 'action' field in response")
-		return nil, fmt.Errorf("missing 'action' field in response")
-	}
-	if result["body"] == nil {
-		utils.Logger.Printf("Missing 'body' field in response")
-		return nil, fmt.Errorf("missing 'body' field in response")
-	}
-
-	utils.Logger.Printf("Successfully parsed action:\n%+v", result)
-	return result, nil
-}
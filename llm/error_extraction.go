@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/prompts"
+	"ai-agent-api-discovery/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Problems the extraction prompt asks the model to classify errors into
+const (
+	ProblemMissing       = "missing"
+	ProblemWrongType     = "wrong_type"
+	ProblemInvalidValue  = "invalid_value"
+	ProblemInvalidFormat = "invalid_format"
+	ProblemTooSmall      = "too_small"
+	ProblemTooLarge      = "too_large"
+	ProblemTooShort      = "too_short"
+	ProblemTooLong       = "too_long"
+	ProblemDuplicate     = "duplicate"
+	ProblemUnknownField  = "unknown_field"
+	ProblemOther         = "other"
+)
+
+// maxExtractionBody caps how much of an error body is sent for extraction
+const maxExtractionBody = 4000
+
+// FieldProblem is a field-level problem the model extracted from an error body
+type FieldProblem struct {
+	Path         string `json:"path"`
+	Problem      string `json:"problem"`
+	ExpectedType string `json:"expectedType,omitempty"`
+	Constraint   string `json:"constraint,omitempty"`
+}
+
+// ExtractFieldProblems asks the non-reasoning chat model to turn an arbitrary
+// error body into a list of field problems. It is meant as a cheap fallback
+// for errors that none of the known error formats recognise.
+func (c *DeepseekClient) ExtractFieldProblems(statusCode int, errorBody string) ([]FieldProblem, error) {
+	if len(errorBody) > maxExtractionBody {
+		errorBody = errorBody[:maxExtractionBody]
+	}
+
+	prompt, _, err := prompts.Render(prompts.Extraction, nil)
+	if err != nil {
+		return nil, err
+	}
+	messages := []models.Message{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: fmt.Sprintf("HTTP status %d. Error body:\n%s", statusCode, errorBody)},
+	}
+	response, err := c.CompleteWithModel(messages, ModelChat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get extraction completion: %w", err)
+	}
+
+	problems, err := parseFieldProblems(response.Content)
+	if err != nil {
+		return nil, err
+	}
+	utils.Logger.Printf("Extracted %d field problems from error body", len(problems))
+	return problems, nil
+}
+
+// parseFieldProblems reads the JSON array from the model's reply, tolerating
+// code fences and a {"problems": [...]} wrapper
+func parseFieldProblems(content string) ([]FieldProblem, error) {
+	start := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if start == -1 || end <= start {
+		var wrapped struct {
+			Problems []FieldProblem `json:"problems"`
+		}
+		if err := json.Unmarshal([]byte(extractJSONObject(content)), &wrapped); err == nil {
+			return cleanFieldProblems(wrapped.Problems), nil
+		}
+		return nil, fmt.Errorf("no JSON array found in extraction reply: %s", content)
+	}
+
+	var problems []FieldProblem
+	if err := json.Unmarshal([]byte(content[start:end+1]), &problems); err != nil {
+		return nil, fmt.Errorf("failed to parse extraction reply: %w", err)
+	}
+	return cleanFieldProblems(problems), nil
+}
+
+// cleanFieldProblems drops entries without a path and normalises problem names
+func cleanFieldProblems(problems []FieldProblem) []FieldProblem {
+	var cleaned []FieldProblem
+	for _, p := range problems {
+		p.Path = strings.TrimSpace(p.Path)
+		if p.Path == "" {
+			continue
+		}
+		p.Problem = strings.ToLower(strings.TrimSpace(p.Problem))
+		if p.Problem == "" {
+			p.Problem = ProblemOther
+		}
+		cleaned = append(cleaned, p)
+	}
+	return cleaned
+}
+
+// extractJSONObject returns the text between the first { and the last }
+func extractJSONObject(content string) string {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start == -1 || end <= start {
+		return ""
+	}
+	return content[start : end+1]
+}
@@ -0,0 +1,199 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of LLM failure. Errors returned by the client wrap one of these, so
+// callers can use errors.Is to tell a bad key from an empty balance from a
+// hiccup worth waiting out.
+var (
+	ErrAuth      = errors.New("LLM authentication failed")
+	ErrQuota     = errors.New("LLM quota or balance exhausted")
+	ErrTransient = errors.New("LLM temporarily unavailable")
+	ErrRequest   = errors.New("LLM rejected the request")
+)
+
+// Retry settings for a single model in the chain
+const (
+	maxLLMAttempts    = 3
+	llmBaseBackoff    = 2 * time.Second
+	llmMaxBackoff     = 30 * time.Second
+	defaultProvider   = "deepseek"
+	deepseekBaseURL   = "https://api.deepseek.com" // No /v1 needed per docs
+	providerKeySuffix = "_API_KEY"
+)
+
+// APIError is a failed call to one model of one provider
+type APIError struct {
+	Provider   string
+	Model      ModelType
+	StatusCode int // 0 if no response was received
+	Message    string
+	Kind       error // ErrAuth, ErrQuota, ErrTransient or ErrRequest
+	retryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s/%s: %v (status %d): %s", e.Provider, e.Model, e.Kind, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s/%s: %v: %s", e.Provider, e.Model, e.Kind, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// Provider is an OpenAI-compatible chat completions API
+type Provider struct {
+	Name    string
+	BaseURL string
+	APIKey  string
+}
+
+// ChainEntry is one model of the fallback chain
+type ChainEntry struct {
+	Provider string
+	Model    ModelType
+}
+
+func (e ChainEntry) String() string {
+	return e.Provider + "/" + string(e.Model)
+}
+
+var (
+	configMu      sync.RWMutex
+	providers     = map[string]Provider{}
+	fallbackChain []ChainEntry
+)
+
+// RegisterProvider adds an OpenAI-compatible provider. Its API key is read
+// from the environment variable <NAME>_API_KEY.
+func RegisterProvider(name, baseURL string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || baseURL == "" {
+		return fmt.Errorf("provider needs a name and a base URL")
+	}
+	envKey := strings.ToUpper(name) + providerKeySuffix
+	apiKey := os.Getenv(envKey)
+	if apiKey == "" {
+		return fmt.Errorf("%s environment variable is not set for provider %s", envKey, name)
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	providers[name] = Provider{Name: name, BaseURL: strings.TrimRight(baseURL, "/"), APIKey: apiKey}
+	return nil
+}
+
+// SetFallbackChain sets the models tried, in order, after the requested model
+// fails. The spec is a comma-separated list of "model" (a Deepseek model) or
+// "provider/model" entries, e.g. "deepseek-chat,openai/gpt-4o-mini".
+func SetFallbackChain(spec string) error {
+	chain, err := ParseChain(spec)
+	if err != nil {
+		return err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	for _, entry := range chain {
+		if _, known := providers[entry.Provider]; !known && entry.Provider != defaultProvider {
+			return fmt.Errorf("unknown provider %q in fallback chain", entry.Provider)
+		}
+	}
+	fallbackChain = chain
+	return nil
+}
+
+// ParseChain parses a comma-separated list of "model" or "provider/model"
+func ParseChain(spec string) ([]ChainEntry, error) {
+	var chain []ChainEntry
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		provider, model, hasProvider := strings.Cut(item, "/")
+		if !hasProvider {
+			provider, model = defaultProvider, item
+		}
+		if provider == "" || model == "" {
+			return nil, fmt.Errorf("invalid fallback entry %q", item)
+		}
+		chain = append(chain, ChainEntry{Provider: strings.ToLower(provider), Model: ModelType(model)})
+	}
+	return chain, nil
+}
+
+// chainFor returns the requested model followed by the configured fallbacks
+func chainFor(primary ChainEntry) []ChainEntry {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	chain := []ChainEntry{primary}
+	for _, entry := range fallbackChain {
+		if entry != chain[0] {
+			chain = append(chain, entry)
+		}
+	}
+	return chain
+}
+
+// provider returns the connection details of a provider
+func (c *DeepseekClient) provider(name string) (Provider, bool) {
+	if name == defaultProvider {
+		return Provider{Name: defaultProvider, BaseURL: c.apiBaseURL, APIKey: c.apiKey}, true
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// classifyStatus maps an HTTP status and error message to a failure kind
+func classifyStatus(status int, message string) error {
+	lower := strings.ToLower(message)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusPaymentRequired ||
+		strings.Contains(lower, "insufficient balance") || strings.Contains(lower, "quota"):
+		return ErrQuota
+	case status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500:
+		return ErrTransient
+	default:
+		return ErrRequest
+	}
+}
+
+// llmBackoff returns the delay before retry n of the same model
+func llmBackoff(attempt int, apiErr *APIError) time.Duration {
+	if apiErr != nil && apiErr.retryAfter > 0 {
+		if apiErr.retryAfter > llmMaxBackoff {
+			return llmMaxBackoff
+		}
+		return apiErr.retryAfter
+	}
+	delay := llmBaseBackoff << (attempt - 1)
+	if delay > llmMaxBackoff {
+		delay = llmMaxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds
+func parseRetryAfter(header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
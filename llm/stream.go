@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"ai-agent-api-discovery/models"
+	"ai-agent-api-discovery/utils"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxStreamLine caps the size of one server-sent event line
+const maxStreamLine = 1 << 20
+
+// streamReasoning makes completions without tools stream, so reasoning is
+// logged while the model thinks rather than after it has finished
+var streamReasoning bool
+
+// SetStreamReasoning turns streaming of completions on or off. Requests that
+// offer tools are never streamed, since tool calls arrive in fragments.
+func SetStreamReasoning(enabled bool) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	streamReasoning = enabled
+}
+
+// streaming reports whether a request with these options should stream
+func streaming(opts requestOptions) bool {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return streamReasoning && len(opts.tools) == 0
+}
+
+// StreamOptions asks for the usage block in the last chunk of a stream
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// streamChunk is one server-sent event of a streamed completion
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Role             string `json:"role"`
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// readStream assembles a streamed completion, logging the reasoning a line at
+// a time as it arrives
+func readStream(body io.Reader) (*completion, error) {
+	var content, reasoning strings.Builder
+	var pending string // reasoning received since the last logged line
+	result := &completion{Message: models.Message{Role: "assistant"}}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+	done := false
+	for !done && scanner.Scan() {
+		data, isData := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !isData {
+			// Blank separators and keep-alive comments
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("malformed stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			reasoning.WriteString(choice.Delta.ReasoningContent)
+			pending += choice.Delta.ReasoningContent
+			for {
+				line, rest, found := strings.Cut(pending, "\n")
+				if !found {
+					break
+				}
+				utils.Logger.Printf("[reasoning] %s", line)
+				pending = rest
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	if pending != "" {
+		utils.Logger.Printf("[reasoning] %s", pending)
+	}
+	if !done {
+		return nil, fmt.Errorf("stream ended before completion")
+	}
+
+	result.Message.Content = content.String()
+	result.Message.Reasoning = reasoning.String()
+	utils.Logger.Printf("Streamed response:\nContent: %s", result.Message.Content)
+	return result, nil
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"ai-agent-api-discovery/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrBudget is returned instead of calling the LLM once a run's budget is spent
+var ErrBudget = errors.New("LLM budget exhausted")
+
+// Usage is the usage block of a chat completion response
+type Usage struct {
+	PromptTokens          int `json:"prompt_tokens"`
+	CompletionTokens      int `json:"completion_tokens"`
+	TotalTokens           int `json:"total_tokens"`
+	PromptCacheHitTokens  int `json:"prompt_cache_hit_tokens"` // Deepseek
+	PromptCacheMissTokens int `json:"prompt_cache_miss_tokens"`
+	PromptTokensDetails   *struct {
+		CachedTokens int `json:"cached_tokens"` // OpenAI-compatible providers
+	} `json:"prompt_tokens_details,omitempty"`
+}
+
+// cachedTokens returns the prompt tokens served from cache, whichever way the provider reports them
+func (u Usage) cachedTokens() int {
+	if u.PromptCacheHitTokens > 0 {
+		return u.PromptCacheHitTokens
+	}
+	if u.PromptTokensDetails != nil {
+		return u.PromptTokensDetails.CachedTokens
+	}
+	return 0
+}
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input       float64 `json:"input"`       // prompt tokens not served from cache
+	CachedInput float64 `json:"cachedInput"` // prompt tokens served from cache
+	Output      float64 `json:"output"`      // completion tokens, reasoning included
+}
+
+// prices maps "model" or "provider/model" to its price. The defaults are
+// Deepseek's published standard rates; load a file to override or extend them.
+var prices = map[string]Price{
+	string(ModelChat): {Input: 0.27, CachedInput: 0.07, Output: 1.10},
+	string(ModelR1):   {Input: 0.55, CachedInput: 0.14, Output: 2.19},
+}
+
+// LoadPriceTable reads a JSON object of prices keyed by "model" or
+// "provider/model", e.g. {"openai/gpt-4o-mini": {"input": 0.15, "output": 0.6}},
+// and merges it over the defaults
+func LoadPriceTable(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read price table: %w", err)
+	}
+	var table map[string]Price
+	if err := json.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("failed to parse price table: %w", err)
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	for model, price := range table {
+		prices[model] = price
+	}
+	return nil
+}
+
+// estimateCost prices a call; models without a price cost nothing
+func estimateCost(entry ChainEntry, usage Usage) float64 {
+	configMu.RLock()
+	price, ok := prices[entry.String()]
+	if !ok {
+		price, ok = prices[string(entry.Model)]
+	}
+	configMu.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	cached := usage.cachedTokens()
+	return (float64(usage.PromptTokens-cached)*price.Input +
+		float64(cached)*price.CachedInput +
+		float64(usage.CompletionTokens)*price.Output) / 1e6
+}
+
+// SetBudget limits what the client may spend from now on
+func (c *DeepseekClient) SetBudget(budget models.LLMBudget) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+}
+
+// Usage returns a copy of the client's token usage so far
+func (c *DeepseekClient) Usage() *models.LLMUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	usage := c.usage
+	usage.Requests = append([]models.LLMCall(nil), c.usage.Requests...)
+	return &usage
+}
+
+// checkBudget counts a new call and fails if the budget does not allow it
+func (c *DeepseekClient) checkBudget() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.budget.MaxLLMCalls > 0 && c.usage.Calls >= c.budget.MaxLLMCalls:
+		return fmt.Errorf("%w: %d of %d calls used", ErrBudget, c.usage.Calls, c.budget.MaxLLMCalls)
+	case c.budget.MaxTokens > 0 && c.usage.TotalTokens >= c.budget.MaxTokens:
+		return fmt.Errorf("%w: %d of %d tokens used", ErrBudget, c.usage.TotalTokens, c.budget.MaxTokens)
+	case c.budget.MaxCost > 0 && c.usage.EstimatedCost >= c.budget.MaxCost:
+		return fmt.Errorf("%w: $%.4f of $%.4f spent", ErrBudget, c.usage.EstimatedCost, c.budget.MaxCost)
+	}
+	c.usage.Calls++
+	return nil
+}
+
+// recordCall adds one request to the usage totals
+func (c *DeepseekClient) recordCall(entry ChainEntry, start time.Time, usage Usage, err error) {
+	call := models.LLMCall{
+		Time:             start,
+		Provider:         entry.Provider,
+		Model:            string(entry.Model),
+		PromptTokens:     usage.PromptTokens,
+		CachedTokens:     usage.cachedTokens(),
+		CompletionTokens: usage.CompletionTokens,
+		EstimatedCost:    estimateCost(entry, usage),
+		DurationMs:       time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage.PromptTokens += call.PromptTokens
+	c.usage.CachedTokens += call.CachedTokens
+	c.usage.CompletionTokens += call.CompletionTokens
+	c.usage.TotalTokens += usage.TotalTokens
+	c.usage.EstimatedCost += call.EstimatedCost
+	c.usage.Requests = append(c.usage.Requests, call)
+}
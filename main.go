@@ -4,8 +4,14 @@ import (
 	"flag"
 	"log"
 	"os"
+	"strings"
 
+	"ai-agent-api-discovery/agent"
+	"ai-agent-api-discovery/errparse"
 	"ai-agent-api-discovery/handlers"
+	"ai-agent-api-discovery/knowledge"
+	"ai-agent-api-discovery/llm"
+	"ai-agent-api-discovery/prompts"
 	"ai-agent-api-discovery/utils"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +21,18 @@ func main() {
 	// Define command-line flags
 	apiKey := flag.String("api-key", "", "Deepseek API key (required)")
 	port := flag.String("port", "8080", "Port to run the server on")
+	errorRules := flag.String("error-rules", "", "Path to a JSON file of shared error parsing rules (optional)")
+	promptsDir := flag.String("prompts-dir", "", "Directory of .tmpl files overriding the built-in prompt templates by name (optional)")
+	wordlist := flag.String("wordlist", "", "Path to a file of extra candidate field names, one per line (optional)")
+	llmProviders := flag.String("llm-providers", "", "Extra OpenAI-compatible LLM providers as name=baseURL,... with keys in <NAME>_API_KEY (optional)")
+	llmFallback := flag.String("llm-fallback", "", "Models to fall back to, in order, as model or provider/model,... (optional)")
+	actionMode := flag.String("llm-action-mode", llm.ActionModeAuto, "How models are asked for actions: auto, tools, json or text")
+	llmModel := flag.String("llm-model", string(llm.ModelR1), "Model asked for actions unless a request chooses one, as model or provider/model")
+	llmMaxTokens := flag.Int("llm-max-tokens", 8000, "Most completion tokens per call a request may ask for")
+	llmAllowed := flag.String("llm-allowed-models", "", "Only models requests may choose, as model or provider/model,... (optional, default any)")
+	streamReasoning := flag.Bool("llm-stream-reasoning", false, "Stream completions without tools so reasoning is logged as it arrives")
+	knowledgeDir := flag.String("knowledge-dir", "", "Directory of per-host knowledge files that runs learn from and seed from (optional)")
+	llmPrices := flag.String("llm-prices", "", "Path to a JSON file of LLM prices per million tokens, keyed by model or provider/model (optional)")
 	flag.Parse()
 
 	// Validate API key
@@ -28,9 +46,74 @@ func main() {
 	}
 	defer utils.CloseLogger()
 
+	// Load shared error parsing rules
+	if *errorRules != "" {
+		if err := errparse.LoadRulesFile(*errorRules); err != nil {
+			utils.Logger.Fatalf("Failed to load error rules: %v", err)
+		}
+		utils.Logger.Printf("Loaded error rules from %s", *errorRules)
+	}
+
+	// Load prompt template overrides
+	if *promptsDir != "" {
+		if err := prompts.LoadDir(*promptsDir); err != nil {
+			utils.Logger.Fatalf("Failed to load prompt templates: %v", err)
+		}
+		utils.Logger.Printf("Loaded prompt templates from %s (variants: %s)", *promptsDir, strings.Join(prompts.Variants(), ", "))
+	}
+
+	// Load extra candidate field names
+	if *wordlist != "" {
+		if err := agent.LoadWordlist(*wordlist); err != nil {
+			utils.Logger.Fatalf("Failed to load wordlist: %v", err)
+		}
+		utils.Logger.Printf("Loaded candidate field names from %s", *wordlist)
+	}
+
+	// Open the per-host knowledge base
+	if *knowledgeDir != "" {
+		if err := knowledge.Open(*knowledgeDir); err != nil {
+			utils.Logger.Fatalf("Failed to open knowledge base: %v", err)
+		}
+		utils.Logger.Printf("Using knowledge base in %s", *knowledgeDir)
+	}
+
 	// Set API key in environment
 	os.Setenv("DEEPSEEK_API_KEY", *apiKey)
 
+	// Configure the LLM fallback chain
+	for _, entry := range strings.Split(*llmProviders, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, baseURL, _ := strings.Cut(entry, "=")
+		if err := llm.RegisterProvider(name, baseURL); err != nil {
+			utils.Logger.Fatalf("Failed to register LLM provider: %v", err)
+		}
+	}
+	if *llmFallback != "" {
+		if err := llm.SetFallbackChain(*llmFallback); err != nil {
+			utils.Logger.Fatalf("Failed to set LLM fallback chain: %v", err)
+		}
+		utils.Logger.Printf("LLM fallback chain: %s", *llmFallback)
+	}
+	if err := llm.SetDefaultModel(*llmModel); err != nil {
+		utils.Logger.Fatalf("Invalid default LLM model: %v", err)
+	}
+	if err := llm.SetLimits(*llmMaxTokens, *llmAllowed); err != nil {
+		utils.Logger.Fatalf("Invalid LLM limits: %v", err)
+	}
+	if err := llm.SetActionMode(*actionMode); err != nil {
+		utils.Logger.Fatalf("Invalid LLM action mode: %v", err)
+	}
+	llm.SetStreamReasoning(*streamReasoning)
+	if *llmPrices != "" {
+		if err := llm.LoadPriceTable(*llmPrices); err != nil {
+			utils.Logger.Fatalf("Failed to load LLM prices: %v", err)
+		}
+		utils.Logger.Printf("Loaded LLM prices from %s", *llmPrices)
+	}
+
 	// Initialize Gin router
 	router := gin.Default()
 
@@ -49,5 +132,8 @@ func setupRoutes(router *gin.Engine) {
 	api := router.Group("/api")
 	{
 		api.POST("/discover", handlers.DiscoverHandler)
+		api.GET("/knowledge", handlers.ListKnowledgeHandler)
+		api.GET("/knowledge/:host", handlers.GetKnowledgeHandler)
+		api.DELETE("/knowledge/:host", handlers.DeleteKnowledgeHandler)
 	}
 }
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // DiscoverRequest represents the input request to discover an API's schema
 type DiscoverRequest struct {
 	Method        string                 `json:"method"` // e.g., "POST"
@@ -7,30 +9,260 @@ type DiscoverRequest struct {
 	Headers       map[string]string      `json:"headers"`       // e.g., {"Authorization": "Bearer ..."}
 	InitialBody   map[string]interface{} `json:"initialBody"`   // Optional: initial guess at fields
 	MaxIterations int                    `json:"maxIterations"` // Safety limit for iterations
+	ErrorRules    []ErrorRule            `json:"errorRules"`    // Optional: custom error parsing rules, applied before the built-in ones
+	MaxProbes     int                    `json:"maxProbes"`     // Budget for direct probes once a working body is found (negative disables probing)
+	// CandidateFields extends the built-in wordlist of field names tried when
+	// looking for undocumented optional fields. Entries are "name" or
+	// "name:type"; dotted names such as "profile.nickname" are tried only in that object.
+	CandidateFields []string     `json:"candidateFields"`
+	Target          TargetConfig `json:"target"` // Optional: retry, rate limit and circuit breaker settings for the target
+	Budget          LLMBudget    `json:"budget"` // Optional: limits on LLM spend for this run
+	LLM             LLMConfig    `json:"llm"`    // Optional: model and sampling for this run, within the server's limits
+	Hints           Hints        `json:"hints"`  // Optional: what is already known about the endpoint
+	// OpenAPI seeds discovery from a documented operation and asks for a
+	// report of where the target differs from its documentation
+	OpenAPI *OpenAPISeed `json:"openapi"`
+	// ValueSeed seeds the generator of test values, so a run against a
+	// target that answers the same way sends the same values; random if unset
+	ValueSeed *int64 `json:"valueSeed"`
+	// IgnoreKnowledge starts the run from nothing even if the server has a
+	// knowledge base entry for the target host; the run is still recorded
+	IgnoreKnowledge bool `json:"ignoreKnowledge"`
+}
+
+// OpenAPISeed names an operation of an OpenAPI 3 or Swagger 2 document.
+// Exactly one of Spec or SpecURL must be set.
+type OpenAPISeed struct {
+	Spec        interface{} `json:"spec"`        // the document as an object, or its JSON or YAML text
+	SpecURL     string      `json:"specUrl"`     // where to fetch the document
+	OperationID string      `json:"operationId"` // e.g. "createUser"
+}
+
+// Hints is prior knowledge about an endpoint. Discovery starts from it
+// instead of from nothing.
+type Hints struct {
+	Description string                   `json:"description"` // what the endpoint does, in free text
+	Examples    []map[string]interface{} `json:"examples"`    // example request bodies, e.g. from documentation
+	Schema      map[string]interface{}   `json:"schema"`      // partial JSON Schema or OpenAPI schema object of the body
+	KnownFields []string                 `json:"knownFields"` // "name" or "name:type"
+	DoNotSend   []string                 `json:"doNotSend"`   // fields never to send, e.g. "isAdmin"; dotted for nested fields
+}
+
+// LLMConfig chooses the model that proposes actions and how it samples.
+// Zero values use the server defaults.
+type LLMConfig struct {
+	Provider      string   `json:"provider"`      // e.g. "deepseek" or a provider registered with -llm-providers
+	Model         string   `json:"model"`         // e.g. "deepseek-chat"
+	Temperature   *float64 `json:"temperature"`   // 0 to 2 (default depends on the model)
+	MaxTokens     int      `json:"maxTokens"`     // completion tokens per call (default depends on the model)
+	Seed          *int     `json:"seed"`          // for providers that support reproducible sampling
+	PromptVariant string   `json:"promptVariant"` // system prompt variant: "default" or "concise"
+}
+
+// LLMBudget limits what a run may spend on LLM calls. Zero means no limit.
+type LLMBudget struct {
+	MaxTokens   int     `json:"maxTokens"`   // prompt plus completion tokens
+	MaxCost     float64 `json:"maxCost"`     // estimated cost in USD
+	MaxLLMCalls int     `json:"maxLLMCalls"` // completions requested, not counting retries
+}
+
+// TargetConfig tunes how requests are sent to the target API. Zero values use the defaults.
+type TargetConfig struct {
+	TimeoutSeconds    int     `json:"timeoutSeconds"`    // per attempt (default 10)
+	MaxRetries        int     `json:"maxRetries"`        // retries on connection errors, 5xx and 429 (default 2, negative disables)
+	RequestsPerSecond float64 `json:"requestsPerSecond"` // rate limit shared by all runs against the same host (default unlimited)
+	BreakerThreshold  int     `json:"breakerThreshold"`  // consecutive failed attempts before the run is aborted (default 5)
+}
+
+// ErrorRule describes how to extract field errors from a target's own error format.
+// Exactly one of Regex or JSONPath must be set.
+type ErrorRule struct {
+	Name     string `json:"name,omitempty"`
+	Regex    string `json:"regex,omitempty"`    // matched against the raw body; named groups "field" and optionally "param"
+	JSONPath string `json:"jsonPath,omitempty"` // selects error entries, e.g. $.errors[*] or $.missing
+	FieldKey string `json:"fieldKey,omitempty"` // key of the field name in selected objects (default "field")
+	ParamKey string `json:"paramKey,omitempty"` // key of the rule parameter in selected objects
+	Semantic string `json:"semantic"`           // required, type, enum or range (any errparse rule is accepted)
+	Param    string `json:"param,omitempty"`    // fixed parameter, e.g. the expected type, when none is captured
 }
 
 // DiscoveredSchema represents the final output of field discovery
 type DiscoveredSchema struct {
+	Fields               []FieldInfo            `json:"fields"`
+	MinimalRequestBody   map[string]interface{} `json:"minimalRequestBody"`
+	Relationships        []FieldRelationship    `json:"relationships,omitempty"`
+	Discriminator        string                 `json:"discriminator,omitempty"` // field that selects one of Variants
+	Variants             []SchemaVariant        `json:"variants,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"` // whether the top-level object accepts unknown fields
+	Run                  *RunRecord             `json:"run,omitempty"`                  // what the run sent to the target
+	Drift                *DriftReport           `json:"drift,omitempty"`                // differences from the OpenAPI operation, if one was given
+}
+
+// Kinds of drift between an OpenAPI operation and the target
+const (
+	DriftRequiredNotEnforced  = "required_not_enforced" // documented as required, but the target accepts bodies without it
+	DriftOptionalRequired     = "optional_required"     // documented as optional, but the target requires it
+	DriftUndocumentedRequired = "undocumented_required" // not documented, but the target requires it
+	DriftUndocumentedField    = "undocumented_field"    // not documented, but the target accepts it
+	DriftFieldRejected        = "field_rejected"        // documented, but the target rejects it as unknown
+	DriftTypeMismatch         = "type_mismatch"         // the target rejects a value of the documented type
+	DriftEnumValueRejected    = "enum_value_rejected"   // the target rejects a documented enum value
+	DriftEnumNotEnforced      = "enum_not_enforced"     // the target accepts a value outside the documented enum
+	DriftAdditionalProperties = "additional_properties" // the target treats unknown fields otherwise than documented
+	DriftBoundaryRejected     = "boundary_rejected"     // the target rejects a value on a documented bound
+	DriftConstraintIgnored    = "constraint_ignored"    // the target accepts a value beyond a documented bound or format
+)
+
+// DriftReport lists where the target differs from an OpenAPI operation
+type DriftReport struct {
+	OperationID string      `json:"operationId"`
+	Items       []DriftItem `json:"items"`
+	Verified    []string    `json:"verified"`            // claims the target confirmed, e.g. "name: required"
+	Unchecked   []string    `json:"unchecked,omitempty"` // claims that could not be tested, with the reason
+}
+
+// DriftItem is one difference between the documentation and the target
+type DriftItem struct {
+	Field      string      `json:"field,omitempty"` // dotted path; empty for the body as a whole
+	Kind       string      `json:"kind"`
+	Documented interface{} `json:"documented,omitempty"`
+	Observed   interface{} `json:"observed,omitempty"`
+	Detail     string      `json:"detail"`
+}
+
+// Purposes of requests sent to the target
+const (
+	PurposeAction = "action" // request proposed by the LLM
+	PurposeProbe  = "probe"  // direct probe sent outside the LLM loop
+)
+
+// RunRecord summarises the requests a discovery run sent to the target.
+// Retries are counted separately so they are not mistaken for real probes.
+type RunRecord struct {
+	Requests  int        `json:"requests"` // logical requests, not counting retries
+	Probes    int        `json:"probes"`   // logical requests that were probes
+	Retries   int        `json:"retries"`
+	Exchanges []Exchange `json:"exchanges"`
+	LLM       *LLMUsage  `json:"llm,omitempty"`
+	// Traces holds the reasoning behind each action the LLM chose
+	Traces []ReasoningTrace `json:"traces,omitempty"`
+	// Prompts maps each prompt template the run used to its version
+	Prompts map[string]string `json:"prompts,omitempty"`
+	// ValueSeed is the seed of the run's test values, to repeat the run with
+	ValueSeed int64 `json:"valueSeed"`
+	// KnowledgeHost is the host whose knowledge base entry seeded the run, if any
+	KnowledgeHost string `json:"knowledgeHost,omitempty"`
+}
+
+// ReasoningTrace is a reasoning model's thinking for one reply of one iteration
+type ReasoningTrace struct {
+	Iteration int    `json:"iteration"`
+	Attempt   int    `json:"attempt"`          // 0 for the first reply, then one per repair
+	Action    string `json:"action,omitempty"` // what the reply asked for, as JSON
+	Error     string `json:"error,omitempty"`  // why the reply could not be used
+	Reasoning string `json:"reasoning"`
+}
+
+// LLMUsage is the token usage and estimated cost of a run's LLM calls
+type LLMUsage struct {
+	Calls            int       `json:"calls"`
+	PromptTokens     int       `json:"promptTokens"`
+	CachedTokens     int       `json:"cachedTokens"` // prompt tokens served from the provider's cache
+	CompletionTokens int       `json:"completionTokens"`
+	TotalTokens      int       `json:"totalTokens"`
+	EstimatedCost    float64   `json:"estimatedCost"` // USD, from the configured price table
+	Requests         []LLMCall `json:"requests"`
+}
+
+// LLMCall is one request sent to an LLM provider
+type LLMCall struct {
+	Time             time.Time `json:"time"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"promptTokens"`
+	CachedTokens     int       `json:"cachedTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	EstimatedCost    float64   `json:"estimatedCost"`
+	DurationMs       int64     `json:"durationMs"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// Exchange is a single attempt to send a request to the target
+type Exchange struct {
+	Time       time.Time `json:"time"`
+	Purpose    string    `json:"purpose"`
+	Attempt    int       `json:"attempt"` // 1 for the first try
+	IsRetry    bool      `json:"isRetry"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// SchemaVariant is one body shape of a discriminated union (JSON Schema oneOf).
+// Fields lists only what the variant adds to the fields shared by all variants.
+type SchemaVariant struct {
+	DiscriminatorValue interface{}            `json:"discriminatorValue"`
 	Fields             []FieldInfo            `json:"fields"`
-	MinimalRequestBody map[string]interface{} `json:"minimalRequestBody"`
+	MinimalRequestBody map[string]interface{} `json:"minimalRequestBody,omitempty"`
+	Discovered         bool                   `json:"discovered"` // false if probing never found a working body
 }
 
+// Relationship types between fields
+const (
+	RelationshipMinimalSet  = "part_of_minimal_set" // fields that must be present together
+	RelationshipConflicts   = "conflicts"           // fields that cannot be sent together (JSON Schema oneOf)
+	RelationshipConditional = "conditional"         // fields required only under a condition (JSON Schema if/then)
+)
+
+// FieldRelationship describes a dependency between fields. Conditional
+// relationships map onto JSON Schema if/then: If holds field values (or
+// IfAbsent lists missing fields) and ThenRequired lists what becomes required.
+type FieldRelationship struct {
+	Type         string                 `json:"type"`
+	Fields       []string               `json:"fields"`
+	If           map[string]interface{} `json:"if,omitempty"`
+	IfAbsent     []string               `json:"ifAbsent,omitempty"`
+	ThenRequired []string               `json:"thenRequired,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+}
+
+// Confidence levels and evidence for optional fields found by probing
+// candidate names rather than from the target's errors
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+
+	EvidenceEcho       = "echo"       // the response contains the value that was sent
+	EvidenceValidation = "validation" // the target validated the value and rejected it
+	EvidenceStrict     = "strict"     // accepted by an object known to reject unknown fields
+	EvidenceBehaviour  = "behaviour"  // sending the field changed the response
+	EvidenceHint       = "hint"       // taken from the request's hints and not confirmed by the target
+)
+
 // FieldInfo represents information about a discovered field
 type FieldInfo struct {
-	Name           string       `json:"name"`
-	Type           string       `json:"type"`              // string, integer, boolean, object, array, etc.
-	Format         string       `json:"format,omitempty"`  // email, date, uuid, etc.
-	Pattern        string       `json:"pattern,omitempty"` // regex pattern if applicable
-	MinLength      *int         `json:"minLength,omitempty"`
-	MaxLength      *int         `json:"maxLength,omitempty"`
-	Minimum        *float64     `json:"minimum,omitempty"`
-	Maximum        *float64     `json:"maximum,omitempty"`
-	Enum           []string     `json:"enum,omitempty"` // possible values if field is enumerated
-	Children       []FieldInfo  `json:"children,omitempty"`
-	SampleValue    interface{}  `json:"sampleValue,omitempty"`
-	Description    string       `json:"description,omitempty"`
-	IsInMinimalSet bool         `json:"isInMinimalSet"`        // whether this field is part of minimal set
-	TestResults    *TestResults `json:"testResults,omitempty"` // results of field testing
+	Name                 string       `json:"name"`
+	Type                 string       `json:"type"`              // string, integer, boolean, object, array, etc.
+	Format               string       `json:"format,omitempty"`  // email, date, uuid, etc.
+	Pattern              string       `json:"pattern,omitempty"` // regex pattern if applicable
+	MinLength            *int         `json:"minLength,omitempty"`
+	MaxLength            *int         `json:"maxLength,omitempty"`
+	Minimum              *float64     `json:"minimum,omitempty"`
+	Maximum              *float64     `json:"maximum,omitempty"`
+	Enum                 []string     `json:"enum,omitempty"` // possible values if field is enumerated
+	Children             []FieldInfo  `json:"children,omitempty"`
+	SampleValue          interface{}  `json:"sampleValue,omitempty"`
+	Description          string       `json:"description,omitempty"`
+	Unique               bool         `json:"unique,omitempty"`               // whether the target rejects values already in use
+	Nullable             *bool        `json:"nullable,omitempty"`             // whether an explicit null is accepted
+	AllowEmpty           *bool        `json:"allowEmpty,omitempty"`           // whether "", 0, [] or {} is accepted
+	DefaultOnOmission    interface{}  `json:"defaultOnOmission,omitempty"`    // value the target reports when the field is omitted
+	AdditionalProperties *bool        `json:"additionalProperties,omitempty"` // for objects, whether unknown fields are accepted
+	Confidence           string       `json:"confidence,omitempty"`           // for fields found by probing candidate names: high, medium or low
+	Evidence             string       `json:"evidence,omitempty"`             // what confirmed a probed field: echo, validation, strict or behaviour
+	IsInMinimalSet       bool         `json:"isInMinimalSet"`                 // whether this field is part of minimal set
+	TestResults          *TestResults `json:"testResults,omitempty"`          // results of field testing
 }
 
 // TestResults represents the results of field testing
@@ -73,4 +305,7 @@ type HTTPResponse struct {
 type Message struct {
 	Role    string `json:"role"`    // system, user, assistant
 	Content string `json:"content"` // the actual message content
+	// Reasoning is the model's reasoning trace, if it returned one. It is
+	// never sent back to the API, which rejects it in input messages.
+	Reasoning string `json:"-"`
 }
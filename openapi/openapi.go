@@ -0,0 +1,241 @@
+// Package openapi reads the request body schema of one operation from an
+// OpenAPI 3 or Swagger 2 document, in JSON or YAML, with local $refs resolved.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Limits on fetching a document by URL
+const (
+	fetchTimeout    = 15 * time.Second
+	maxDocumentSize = 10 << 20
+)
+
+// methods are the operation keys of a path item
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Document is a parsed OpenAPI or Swagger document
+type Document struct {
+	root map[string]interface{}
+}
+
+// Operation is one documented operation and the schema of its JSON body
+type Operation struct {
+	ID     string
+	Method string // upper case, e.g. POST
+	Path   string // as documented, e.g. /users/{id}
+	// Schema is the request body schema with every local $ref resolved and
+	// allOf merged; nil if the operation documents no JSON body
+	Schema map[string]interface{}
+}
+
+// Parse reads a document in JSON or YAML
+func Parse(data []byte) (*Document, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	// Round-trip through JSON so numbers and maps have the types encoding/json gives them
+	normalised, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(normalised, &root); err != nil {
+		return nil, fmt.Errorf("OpenAPI document is not an object: %w", err)
+	}
+	if root["openapi"] == nil && root["swagger"] == nil {
+		return nil, fmt.Errorf("document has neither an openapi nor a swagger version field")
+	}
+	return &Document{root: root}, nil
+}
+
+// Fetch downloads and parses a document
+func Fetch(url string) (*Document, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenAPI document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OpenAPI document: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAPI document: %w", err)
+	}
+	return Parse(data)
+}
+
+// Raw returns the document as decoded JSON
+func (d *Document) Raw() map[string]interface{} {
+	return d.root
+}
+
+// Operation finds an operation by its operationId
+func (d *Document) Operation(id string) (*Operation, error) {
+	paths, _ := d.root["paths"].(map[string]interface{})
+	var known []string
+	for _, path := range sortedKeys(paths) {
+		item, _ := d.resolve(paths[path], nil).(map[string]interface{})
+		for _, method := range methods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			opID, _ := op["operationId"].(string)
+			if opID != id {
+				if opID != "" {
+					known = append(known, opID)
+				}
+				continue
+			}
+			schema, err := d.bodySchema(item, op)
+			if err != nil {
+				return nil, fmt.Errorf("operation %s: %w", id, err)
+			}
+			return &Operation{ID: id, Method: strings.ToUpper(method), Path: path, Schema: schema}, nil
+		}
+	}
+	return nil, fmt.Errorf("no operation %q in the document (found: %s)", id, strings.Join(known, ", "))
+}
+
+// bodySchema returns the JSON request body schema of an operation
+func (d *Document) bodySchema(item, op map[string]interface{}) (map[string]interface{}, error) {
+	// OpenAPI 3
+	if body, ok := d.resolve(op["requestBody"], nil).(map[string]interface{}); ok {
+		content, _ := body["content"].(map[string]interface{})
+		for _, mediaType := range sortedKeys(content) {
+			if !strings.Contains(mediaType, "json") {
+				continue
+			}
+			media, _ := content[mediaType].(map[string]interface{})
+			schema, _ := d.resolve(media["schema"], nil).(map[string]interface{})
+			return schema, nil
+		}
+		return nil, fmt.Errorf("request body has no JSON content")
+	}
+
+	// Swagger 2: a parameter "in: body", on the operation or the path item
+	params := append(anyList(op["parameters"]), anyList(item["parameters"])...)
+	for _, p := range params {
+		param, _ := d.resolve(p, nil).(map[string]interface{})
+		if param["in"] == "body" {
+			schema, _ := d.resolve(param["schema"], nil).(map[string]interface{})
+			return schema, nil
+		}
+	}
+	return nil, nil
+}
+
+// resolve returns a copy of node with local $refs replaced by their targets
+// and allOf merged. Recursive references resolve to an empty object.
+func (d *Document) resolve(node interface{}, stack []string) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := n["$ref"].(string); ok {
+			for _, seen := range stack {
+				if seen == ref {
+					return map[string]interface{}{"type": "object"}
+				}
+			}
+			target, err := d.lookup(ref)
+			if err != nil {
+				return map[string]interface{}{}
+			}
+			return d.resolve(target, append(stack, ref))
+		}
+		out := make(map[string]interface{}, len(n))
+		for key, value := range n {
+			out[key] = d.resolve(value, stack)
+		}
+		if allOf, ok := out["allOf"].([]interface{}); ok {
+			delete(out, "allOf")
+			for _, part := range allOf {
+				if partMap, ok := part.(map[string]interface{}); ok {
+					mergeSchema(out, partMap)
+				}
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, value := range n {
+			out[i] = d.resolve(value, stack)
+		}
+		return out
+	default:
+		return node
+	}
+}
+
+// lookup follows a local JSON pointer such as #/components/schemas/User
+func (d *Document) lookup(ref string) (interface{}, error) {
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("only local references are supported, got %q", ref)
+	}
+	var current interface{} = d.root
+	for _, token := range strings.Split(pointer, "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("reference %q not found", ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("reference %q not found", ref)
+		}
+	}
+	return current, nil
+}
+
+// mergeSchema merges an allOf part into a schema: properties and required
+// are combined, anything else the schema lacks is copied
+func mergeSchema(schema, part map[string]interface{}) {
+	for key, value := range part {
+		switch key {
+		case "properties":
+			properties, _ := schema["properties"].(map[string]interface{})
+			if properties == nil {
+				properties = map[string]interface{}{}
+			}
+			partProperties, _ := value.(map[string]interface{})
+			for name, prop := range partProperties {
+				properties[name] = prop
+			}
+			schema["properties"] = properties
+		case "required":
+			schema["required"] = append(anyList(schema["required"]), anyList(value)...)
+		default:
+			if _, exists := schema[key]; !exists {
+				schema[key] = value
+			}
+		}
+	}
+}
+
+// anyList reads a JSON array
+func anyList(value interface{}) []interface{} {
+	list, _ := value.([]interface{})
+	return list
+}
+
+// sortedKeys returns the keys of an object in order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
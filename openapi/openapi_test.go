@@ -0,0 +1,173 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// usersYAML is an OpenAPI 3 document with refs, allOf and a recursive schema
+const usersYAML = `
+openapi: 3.0.3
+info: {title: Users, version: "1"}
+paths:
+  /users:
+    post:
+      operationId: createUser
+      requestBody:
+        $ref: '#/components/requestBodies/NewUser'
+    get:
+      operationId: listUsers
+  /users/{id}/avatar:
+    put:
+      operationId: setAvatar
+      requestBody:
+        content:
+          image/png: {schema: {type: string, format: binary}}
+components:
+  requestBodies:
+    NewUser:
+      content:
+        text/plain: {schema: {type: string}}
+        application/json:
+          schema:
+            allOf:
+              - $ref: '#/components/schemas/Base'
+              - type: object
+                required: [email]
+                properties:
+                  email: {type: string, format: email}
+                  manager: {$ref: '#/components/schemas/Person'}
+  schemas:
+    Base:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string, example: Ada}
+    Person:
+      type: object
+      properties:
+        name: {type: string}
+        manager: {$ref: '#/components/schemas/Person'}
+`
+
+func TestOperationResolvesRefsAndAllOf(t *testing.T) {
+	doc, err := Parse([]byte(usersYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err := doc.Operation("createUser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Method != "POST" || op.Path != "/users" {
+		t.Errorf("operation = %s %s", op.Method, op.Path)
+	}
+	want := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name", "email"},
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string", "example": "Ada"},
+			"email": map[string]interface{}{"type": "string", "format": "email"},
+			"manager": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+					// The recursive reference stops at an empty object
+					"manager": map[string]interface{}{"type": "object"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(op.Schema, want) {
+		t.Errorf("schema = %#v\nwant %#v", op.Schema, want)
+	}
+}
+
+func TestOperationWithoutJSONBody(t *testing.T) {
+	doc, err := Parse([]byte(usersYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err := doc.Operation("listUsers")
+	if err != nil || op.Schema != nil {
+		t.Errorf("listUsers = %+v, %v; want no schema", op, err)
+	}
+	if _, err := doc.Operation("setAvatar"); err == nil || !strings.Contains(err.Error(), "no JSON content") {
+		t.Errorf("setAvatar error = %v", err)
+	}
+	_, err = doc.Operation("deleteUser")
+	if err == nil || !strings.Contains(err.Error(), "found: listUsers, createUser, setAvatar") {
+		t.Errorf("deleteUser error = %v", err)
+	}
+}
+
+func TestSwaggerBodyParameter(t *testing.T) {
+	doc, err := Parse([]byte(`{
+		"swagger": "2.0",
+		"paths": {"/pets": {
+			"parameters": [{"$ref": "#/parameters/PetBody"}],
+			"post": {"operationId": "addPet"}
+		}},
+		"parameters": {"PetBody": {"in": "body", "name": "pet", "schema": {"$ref": "#/definitions/Pet"}}},
+		"definitions": {"Pet": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err := doc.Operation("addPet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Method != "POST" || op.Schema["required"] == nil || op.Schema["properties"] == nil {
+		t.Errorf("addPet = %s %#v", op.Method, op.Schema)
+	}
+}
+
+func TestParseRejectsOtherDocuments(t *testing.T) {
+	for _, data := range []string{`{"name": "not an API"}`, `[1, 2]`, `openapi: [`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s) succeeded", data)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	doc := &Document{root: map[string]interface{}{
+		"definitions": map[string]interface{}{"a/b": "slash", "c~d": "tilde"},
+	}}
+	for ref, want := range map[string]interface{}{"#/definitions/a~1b": "slash", "#/definitions/c~0d": "tilde"} {
+		if got, err := doc.lookup(ref); err != nil || got != want {
+			t.Errorf("lookup(%s) = %v, %v", ref, got, err)
+		}
+	}
+	for _, ref := range []string{"#/definitions/missing", "other.yaml#/Pet", "#/definitions/a~1b/deeper"} {
+		if _, err := doc.lookup(ref); err == nil {
+			t.Errorf("lookup(%s) succeeded", ref)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openapi.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(usersYAML))
+	}))
+	defer server.Close()
+
+	doc, err := Fetch(server.URL + "/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.Operation("createUser"); err != nil {
+		t.Error(err)
+	}
+	if _, err := Fetch(server.URL + "/missing.yaml"); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("missing document error = %v", err)
+	}
+}
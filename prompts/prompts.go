@@ -0,0 +1,211 @@
+// Package prompts holds the LLM prompts as versioned text/templates. The
+// templates are embedded in the binary and can be overridden by name from a
+// directory.
+package prompts
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Names of the templates the agent renders
+const (
+	Snapshot   = "snapshot"
+	Repair     = "repair"
+	Extraction = "extraction"
+	// systemPrefix starts the name of each system prompt variant
+	systemPrefix = "system."
+	// DefaultVariant is the system prompt used unless a request chooses another
+	DefaultVariant = "default"
+)
+
+// templateExt is the extension of template files
+const templateExt = ".tmpl"
+
+//go:embed templates/*.tmpl
+var embedded embed.FS
+
+// versionRegex reads the version from a template's first line
+var versionRegex = regexp.MustCompile(`^\{\{/\*\s*version:\s*(\S+)\s*\*/`)
+
+// SystemData is rendered into the system prompt
+type SystemData struct {
+	Method         string
+	URL            string
+	Description    string   // what the endpoint does, from the hints
+	Examples       []string // example bodies from the hints, as JSON
+	KnownFields    []string // fields known before the run, with type and sample where known
+	RequiredFields []string // fields the hinted schema lists as required
+	DoNotSend      []string // fields that are removed from every request
+	HostKnowledge  []string // what earlier runs learned about the target host
+}
+
+// SnapshotData is rendered into the user message of each iteration
+type SnapshotData struct {
+	Method        string
+	URL           string
+	Step          int // position of this step in the history
+	Iteration     int // iterations used, counting this one
+	MaxIterations int
+	StartBody     bool     // whether the run started from a provided body
+	State         string   // current body, minimal body, headers and field status
+	OpenQuestions []string // what discovery still has to find out
+	Earlier       []string // one line per older step
+	Recent        []string // the last few steps in full
+}
+
+// RepairData is rendered into the message asking the model to fix its reply
+type RepairData struct {
+	Error string
+}
+
+// prompt is a parsed template and its version
+type prompt struct {
+	tmpl    *template.Template
+	version string
+}
+
+var (
+	mu      sync.RWMutex
+	loaded  = map[string]prompt{}
+	funcMap = template.FuncMap{
+		"join": strings.Join,
+		"json": func(v interface{}) string {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Sprintf("%v", v)
+			}
+			return string(data)
+		},
+	}
+)
+
+func init() {
+	entries, err := embedded.ReadDir("templates")
+	if err != nil {
+		panic(fmt.Sprintf("prompts: failed to read embedded templates: %v", err))
+	}
+	for _, entry := range entries {
+		data, err := embedded.ReadFile("templates/" + entry.Name())
+		if err != nil {
+			panic(fmt.Sprintf("prompts: failed to read %s: %v", entry.Name(), err))
+		}
+		p, err := parse(entry.Name(), string(data))
+		if err != nil {
+			panic(fmt.Sprintf("prompts: %v", err))
+		}
+		loaded[strings.TrimSuffix(entry.Name(), templateExt)] = p
+	}
+}
+
+// LoadDir overrides templates with the .tmpl files of a directory, by name.
+// Files with new names add templates, such as further system prompt variants.
+func LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	if err != nil {
+		return fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no %s files in %s", templateExt, dir)
+	}
+
+	parsed := make(map[string]prompt, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		p, err := parse(filepath.Base(path), string(data))
+		if err != nil {
+			return err
+		}
+		parsed[strings.TrimSuffix(filepath.Base(path), templateExt)] = p
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for name, p := range parsed {
+		loaded[name] = p
+	}
+	return nil
+}
+
+// parse parses a template file and reads its version. Templates without a
+// version line are versioned by a hash of their text.
+func parse(file, text string) (prompt, error) {
+	tmpl, err := template.New(file).Funcs(funcMap).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return prompt{}, fmt.Errorf("failed to parse prompt template %s: %w", file, err)
+	}
+	version := ""
+	if match := versionRegex.FindStringSubmatch(text); match != nil {
+		version = match[1]
+	} else {
+		sum := sha256.Sum256([]byte(text))
+		version = "sha256:" + hex.EncodeToString(sum[:4])
+	}
+	return prompt{tmpl: tmpl, version: version}, nil
+}
+
+// Render renders a template and returns the text with the template's version
+func Render(name string, data interface{}) (string, string, error) {
+	mu.RLock()
+	p, ok := loaded[name]
+	mu.RUnlock()
+	if !ok {
+		return "", "", fmt.Errorf("unknown prompt template %q", name)
+	}
+
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+	return strings.TrimSpace(b.String()), p.version, nil
+}
+
+// Version returns the version of a template, or "" if there is no such template
+func Version(name string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return loaded[name].version
+}
+
+// System returns the template name of a system prompt variant, or an error
+// if there is no such variant. "" is the default variant.
+func System(variant string) (string, error) {
+	if variant == "" {
+		variant = DefaultVariant
+	}
+	name := systemPrefix + variant
+	mu.RLock()
+	_, ok := loaded[name]
+	mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown prompt variant %q (available: %s)", variant, strings.Join(Variants(), ", "))
+	}
+	return name, nil
+}
+
+// Variants lists the system prompt variants
+func Variants() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	var variants []string
+	for name := range loaded {
+		if variant, ok := strings.CutPrefix(name, systemPrefix); ok {
+			variants = append(variants, variant)
+		}
+	}
+	sort.Strings(variants)
+	return variants
+}
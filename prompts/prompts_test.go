@@ -0,0 +1,140 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// restoreLoaded puts the loaded templates back after a test overrides them
+func restoreLoaded(t *testing.T) {
+	mu.RLock()
+	saved := make(map[string]prompt, len(loaded))
+	for name, p := range loaded {
+		saved[name] = p
+	}
+	mu.RUnlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		loaded = saved
+	})
+}
+
+func TestEmbeddedTemplatesRender(t *testing.T) {
+	system := SystemData{
+		Method:         "POST",
+		URL:            "http://localhost:8081/api/users",
+		Description:    "Creates a user",
+		Examples:       []string{`{"email":"a@example.com"}`},
+		KnownFields:    []string{"email (string)"},
+		RequiredFields: []string{"email"},
+		DoNotSend:      []string{"password"},
+		HostKnowledge:  []string{"name (string) - seen on POST /api/teams"},
+	}
+	tests := []struct {
+		name string
+		data interface{}
+		want []string
+	}{
+		{Snapshot, SnapshotData{Method: "POST", URL: "http://localhost:8081/api/users", Step: 3, Iteration: 2, MaxIterations: 20,
+			State: "Current body: {}", OpenQuestions: []string{"Is age required?"}, Earlier: []string{"1. modify_fields sent {} -> 400"}, Recent: []string{"### Step 2"}},
+			[]string{"This is step 3, with 2 of 20 iterations used", "Is age required?", "## Earlier steps", "### Step 2"}},
+		{Snapshot, SnapshotData{Method: "POST", URL: "http://localhost:8081/api/users", Step: 1, Iteration: 1, MaxIterations: 20, StartBody: true},
+			[]string{"Nothing has been sent yet. We start with the provided body."}},
+		{Repair, RepairData{Error: "no JSON object found"}, []string{"Your last reply could not be used: no JSON object found."}},
+		{Extraction, nil, []string{"Reply with only a JSON array"}},
+		{systemPrefix + DefaultVariant, system, []string{"POST http://localhost:8081/api/users", "Creates a user", "password", "seen on POST /api/teams"}},
+		{systemPrefix + "concise", system, []string{"Creates a user", "password", "seen on POST /api/teams"}},
+	}
+	for _, tt := range tests {
+		text, version, err := Render(tt.name, tt.data)
+		if err != nil {
+			t.Errorf("Render(%s): %v", tt.name, err)
+			continue
+		}
+		if strings.HasPrefix(version, "sha256:") {
+			t.Errorf("%s has no version line", tt.name)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(text, want) {
+				t.Errorf("%s does not contain %q:\n%s", tt.name, want, text)
+			}
+		}
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	if _, _, err := Render("nonexistent", nil); err == nil {
+		t.Error("rendered an unknown template")
+	}
+	// A template that names a field the data lacks fails instead of rendering "<no value>"
+	if _, _, err := Render(Repair, SnapshotData{}); err == nil {
+		t.Error("rendered the repair template with the wrong data")
+	}
+}
+
+func TestLoadDirOverridesByName(t *testing.T) {
+	restoreLoaded(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"repair.tmpl":       "{{/* version: 7 */ -}}\nTry again: {{.Error}}",
+		"system.terse.tmpl": "Discover {{.Method}} {{.URL}}.",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	text, version, err := Render(Repair, RepairData{Error: "bad JSON"})
+	if err != nil || text != "Try again: bad JSON" || version != "7" {
+		t.Errorf("repair = %q version %q (%v)", text, version, err)
+	}
+	// Templates without a version line are versioned by their content
+	if version := Version("system.terse"); !strings.HasPrefix(version, "sha256:") {
+		t.Errorf("system.terse version = %q", version)
+	}
+	// Templates the directory does not override are kept
+	if _, _, err := Render(Extraction, nil); err != nil {
+		t.Errorf("extraction: %v", err)
+	}
+	if name, err := System("terse"); err != nil || name != "system.terse" {
+		t.Errorf("System(terse) = %q, %v", name, err)
+	}
+	if got := strings.Join(Variants(), ","); got != "concise,default,terse" {
+		t.Errorf("Variants() = %s", got)
+	}
+}
+
+func TestLoadDirRejectsInvalidTemplates(t *testing.T) {
+	restoreLoaded(t)
+	if err := LoadDir(t.TempDir()); err == nil {
+		t.Error("loaded a directory without templates")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "good.tmpl"), []byte("fine"), 0644)
+	os.WriteFile(filepath.Join(dir, "repair.tmpl"), []byte("{{.Error"), 0644)
+	if err := LoadDir(dir); err == nil {
+		t.Fatal("loaded a template that does not parse")
+	}
+	// Nothing is replaced when any template in the directory is invalid
+	if Version("good") != "" || Version(Repair) != "1" {
+		t.Errorf("a failed load changed the templates: good=%q repair=%q", Version("good"), Version(Repair))
+	}
+}
+
+func TestSystemUnknownVariant(t *testing.T) {
+	_, err := System("verbose")
+	if err == nil || !strings.Contains(err.Error(), "available: concise, default") {
+		t.Errorf("System(verbose) error = %v", err)
+	}
+	if name, err := System(""); err != nil || name != systemPrefix+DefaultVariant {
+		t.Errorf("System(\"\") = %q, %v", name, err)
+	}
+}
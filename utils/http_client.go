@@ -3,33 +3,158 @@ package utils
 import (
 	"ai-agent-api-discovery/models"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 )
 
-var client = &http.Client{
-	Timeout: 10 * time.Second,
+// Defaults for TargetConfig fields left at zero
+const (
+	defaultTimeout          = 10 * time.Second
+	defaultMaxRetries       = 2
+	defaultBreakerThreshold = 5
+	baseBackoff             = 500 * time.Millisecond
+	maxBackoff              = 10 * time.Second
+	maxRetryAfter           = 60 * time.Second
+)
+
+// ErrCircuitOpen is returned once the target has failed too many times in a
+// row; the run should stop rather than keep sending requests
+var ErrCircuitOpen = errors.New("circuit breaker open: target appears to be down")
+
+// TargetClient sends requests to a target API with retries, per-host rate
+// limiting and a circuit breaker, and records every attempt
+type TargetClient struct {
+	config     models.TargetConfig
+	httpClient *http.Client
+	ctx        context.Context // cancels requests when the run is abandoned
+
+	mu       sync.Mutex
+	failures int // consecutive failed attempts
+	open     bool
+	record   models.RunRecord
 }
 
-// DoRequest makes an HTTP request to the specified URL with the given method, headers, and body
-func DoRequest(method, url string, headers map[string]string, body interface{}) (*models.HTTPResponse, error) {
-	var jsonData []byte
-	var err error
-	var req *http.Request
+// NewTargetClient creates a client for one discovery run
+func NewTargetClient(config models.TargetConfig) *TargetClient {
+	timeout := defaultTimeout
+	if config.TimeoutSeconds > 0 {
+		timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	} else if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.BreakerThreshold <= 0 {
+		config.BreakerThreshold = defaultBreakerThreshold
+	}
+	return &TargetClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: timeout},
+		ctx:        context.Background(),
+	}
+}
+
+// SetContext makes later requests stop, without retries, once ctx is done.
+// It must be called before the client is used.
+func (c *TargetClient) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
 
+// Do sends a request, retrying connection errors, 5xx and 429 responses.
+// When retries run out on an error status the last response is returned as
+// usual; ErrCircuitOpen is returned once the breaker has opened.
+func (c *TargetClient) Do(purpose, method, target string, headers map[string]string, body interface{}) (*models.HTTPResponse, error) {
+	var payload []byte
 	if body != nil {
-		jsonData, err = json.Marshal(body)
+		var err error
+		payload, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		req, err = http.NewRequest(method, url, bytes.NewBuffer(jsonData))
-	} else {
-		req, err = http.NewRequest(method, url, nil)
 	}
 
+	c.mu.Lock()
+	c.record.Requests++
+	if purpose == models.PurposeProbe {
+		c.record.Probes++
+	}
+	c.mu.Unlock()
+
+	host := hostOf(target)
+	if c.IsOpen() {
+		return nil, ErrCircuitOpen
+	}
+	for attempt := 1; ; attempt++ {
+		if err := limiterFor(host).wait(c.ctx, c.interval()); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		resp, err := c.send(method, target, headers, payload)
+		c.recordAttempt(purpose, attempt, start, resp, err)
+		if ctxErr := c.ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("request abandoned: %w", ctxErr)
+		}
+		if c.IsOpen() {
+			return nil, ErrCircuitOpen
+		}
+
+		if !retryable(resp, err) || attempt > c.config.MaxRetries {
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		delay := backoff(attempt)
+		if wait, ok := retryAfter(resp); ok {
+			delay = wait
+			limiterFor(host).pause(wait)
+		}
+		if err != nil {
+			Logger.Printf("Request to %s failed (%v), retrying in %v", host, err, delay)
+		} else {
+			Logger.Printf("Request to %s returned %d, retrying in %v", host, resp.StatusCode, delay)
+		}
+		if err := Sleep(c.ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// IsOpen reports whether the circuit breaker has opened
+func (c *TargetClient) IsOpen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.open
+}
+
+// Record returns a copy of what the client has sent so far
+func (c *TargetClient) Record() *models.RunRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record := c.record
+	record.Exchanges = append([]models.Exchange(nil), c.record.Exchanges...)
+	return &record
+}
+
+// send makes a single attempt
+func (c *TargetClient) send(method, target string, headers map[string]string, payload []byte) (*models.HTTPResponse, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(c.ctx, method, target, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -42,25 +167,188 @@ func DoRequest(method, url string, headers map[string]string, body interface{})
 		req.Header.Set(k, v)
 	}
 
-	// Make the request
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Read response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	// Create response object
-	httpResp := &models.HTTPResponse{
+	return &models.HTTPResponse{
 		StatusCode:   resp.StatusCode,
 		ResponseBody: respBody,
 		Headers:      resp.Header,
+	}, nil
+}
+
+// recordAttempt adds an attempt to the run record and updates the breaker.
+// Connection errors and 5xx count as failures; anything else resets the count.
+// Attempts abandoned with the run say nothing about the target and leave the
+// count alone.
+func (c *TargetClient) recordAttempt(purpose string, attempt int, start time.Time, resp *models.HTTPResponse, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exchange := models.Exchange{
+		Time:       start,
+		Purpose:    purpose,
+		Attempt:    attempt,
+		IsRetry:    attempt > 1,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if attempt > 1 {
+		c.record.Retries++
+	}
+
+	failed := err != nil
+	if err != nil {
+		exchange.Error = err.Error()
+	} else {
+		exchange.StatusCode = resp.StatusCode
+		failed = resp.StatusCode >= 500
+	}
+	c.record.Exchanges = append(c.record.Exchanges, exchange)
+
+	if c.ctx.Err() != nil {
+		return
+	}
+	if !failed {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= c.config.BreakerThreshold && !c.open {
+		c.open = true
+		Logger.Printf("Circuit breaker opened after %d consecutive failures", c.failures)
+	}
+}
+
+// interval returns the minimum gap between requests to the same host
+func (c *TargetClient) interval() time.Duration {
+	if c.config.RequestsPerSecond <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / c.config.RequestsPerSecond)
+}
+
+// retryable reports whether an attempt is worth repeating
+func retryable(resp *models.HTTPResponse, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// backoff returns the exponential delay before retry n, with jitter
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff << (attempt - 1)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter reads the Retry-After header of a 429 or 503 response, given
+// either in seconds or as an HTTP date
+func retryAfter(resp *models.HTTPResponse) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	value := http.Header(resp.Headers).Get("Retry-After")
+	if value == "" {
+		return 0, false
 	}
 
-	return httpResp, nil
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = time.Until(at)
+	} else {
+		return 0, false
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
+}
+
+// hostLimiter spaces out requests to one host across all runs
+type hostLimiter struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*hostLimiter{}
+)
+
+// limiterFor returns the shared limiter of a host
+func limiterFor(host string) *hostLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	l, exists := limiters[host]
+	if !exists {
+		l = &hostLimiter{}
+		limiters[host] = l
+	}
+	return l
+}
+
+// wait blocks until the host may be sent another request and reserves the
+// next slot interval later. It returns early if ctx is done.
+func (l *hostLimiter) wait(ctx context.Context, interval time.Duration) error {
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(interval)
+	l.mu.Unlock()
+
+	return Sleep(ctx, time.Until(start))
+}
+
+// pause holds back every request to the host for d, as asked by Retry-After
+func (l *hostLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
+
+// hostOf returns the host:port of a URL, used to key rate limits
+func hostOf(target string) string {
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return target
+}
+
+// Sleep waits for d, or until ctx is done, in which case it returns why
+func Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"ai-agent-api-discovery/models"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func init() {
+	Logger = log.New(io.Discard, "", 0)
+}
+
+// statusServer answers each request with the next status of a script,
+// repeating the last one, and a Retry-After header if retry is set
+func statusServer(t *testing.T, retry string, statuses ...int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n > len(statuses) {
+			n = len(statuses)
+		}
+		if retry != "" {
+			w.Header().Set("Retry-After", retry)
+		}
+		w.WriteHeader(statuses[n-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestDoRetriesUnavailable(t *testing.T) {
+	server, calls := statusServer(t, "0", http.StatusServiceUnavailable, http.StatusOK)
+	client := NewTargetClient(models.TargetConfig{})
+
+	resp, err := client.Do(models.PurposeProbe, "POST", server.URL, nil, map[string]interface{}{"a": 1})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Do = %v, %v", resp, err)
+	}
+	if *calls != 2 {
+		t.Errorf("server saw %d requests, want 2", *calls)
+	}
+
+	record := client.Record()
+	if record.Requests != 1 || record.Probes != 1 || record.Retries != 1 {
+		t.Errorf("record counts requests=%d probes=%d retries=%d", record.Requests, record.Probes, record.Retries)
+	}
+	want := []struct {
+		attempt int
+		retry   bool
+		status  int
+	}{{1, false, http.StatusServiceUnavailable}, {2, true, http.StatusOK}}
+	if len(record.Exchanges) != len(want) {
+		t.Fatalf("exchanges = %+v", record.Exchanges)
+	}
+	for i, w := range want {
+		if e := record.Exchanges[i]; e.Attempt != w.attempt || e.IsRetry != w.retry || e.StatusCode != w.status {
+			t.Errorf("exchange %d = %+v, want attempt %d retry %v status %d", i, e, w.attempt, w.retry, w.status)
+		}
+	}
+}
+
+func TestDoReturnsLastResponseWhenRetriesRunOut(t *testing.T) {
+	server, calls := statusServer(t, "0", http.StatusTooManyRequests)
+	client := NewTargetClient(models.TargetConfig{MaxRetries: 2})
+
+	resp, err := client.Do(models.PurposeProbe, "GET", server.URL, nil, nil)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Do = %v, %v", resp, err)
+	}
+	if *calls != 3 {
+		t.Errorf("server saw %d requests, want 3", *calls)
+	}
+	// 429 is not a failure of the target, so the breaker stays closed
+	if client.IsOpen() {
+		t.Error("breaker opened on rate limiting")
+	}
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	server, calls := statusServer(t, "", http.StatusInternalServerError)
+	client := NewTargetClient(models.TargetConfig{MaxRetries: -1, BreakerThreshold: 2})
+
+	if resp, err := client.Do(models.PurposeProbe, "GET", server.URL, nil, nil); err != nil || resp.StatusCode != 500 {
+		t.Fatalf("first Do = %v, %v", resp, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Do(models.PurposeProbe, "GET", server.URL, nil, nil); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Do after %d failures: %v, want ErrCircuitOpen", i+2, err)
+		}
+	}
+	// Once open, nothing more is sent
+	if *calls != 2 {
+		t.Errorf("server saw %d requests, want 2", *calls)
+	}
+}
+
+func TestDoStopsWhenContextIsCancelled(t *testing.T) {
+	server, calls := statusServer(t, "30", http.StatusServiceUnavailable)
+	client := NewTargetClient(models.TargetConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	client.SetContext(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.Do(models.PurposeProbe, "GET", server.URL, nil, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Do returned after %v", elapsed)
+	}
+	if *calls != 1 {
+		t.Errorf("server saw %d requests, want 1", *calls)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sleep on a cancelled context = %v", err)
+	}
+	if err := Sleep(context.Background(), 0); err != nil {
+		t.Errorf("Sleep(0) = %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	future := time.Now().Add(20 * time.Second).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		status   int
+		header   string
+		min, max time.Duration
+		ok       bool
+	}{
+		{503, "5", 5 * time.Second, 5 * time.Second, true},
+		{429, "0", 0, 0, true},
+		{429, "3600", maxRetryAfter, maxRetryAfter, true},
+		{503, future, 15 * time.Second, 20 * time.Second, true},
+		{503, past, 0, 0, true},
+		{503, "soon", 0, 0, false},
+		{503, "", 0, 0, false},
+		// Only rate limiting and unavailability set how long to wait
+		{500, "5", 0, 0, false},
+	}
+	for _, tt := range tests {
+		resp := &models.HTTPResponse{StatusCode: tt.status, Headers: map[string][]string{}}
+		if tt.header != "" {
+			http.Header(resp.Headers).Set("Retry-After", tt.header)
+		}
+		wait, ok := retryAfter(resp)
+		if ok != tt.ok || wait < tt.min || wait > tt.max {
+			t.Errorf("retryAfter(%d, %q) = %v, %v; want %v-%v, %v", tt.status, tt.header, wait, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+// Package valuegen produces request values for fields: plausible valid values
+// from a field's name, type, format and constraints, edge cases on the
+// boundaries of those constraints, and values the field should reject. The
+// values a generator produces for a field depend only on its seed, the
+// field's name and how many values it was asked for before, not on the order
+// in which fields are asked for.
+package valuegen
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotInEnum is a string no real enum is expected to contain
+const NotInEnum = "zzNotInEnum"
+
+// longStringLength is the length of the long-string edge case for fields
+// without a documented maximum length
+const longStringLength = 10000
+
+// unicodeText mixes accented Latin, CJK, right-to-left script and an emoji
+// outside the Basic Multilingual Plane
+const unicodeText = "Zoë 名前 مرحبا 🚀"
+
+// Edge case labels for values that are valid but unusual
+const (
+	EdgeEnum      = "enum"
+	EdgeMinimum   = "minimum"
+	EdgeMaximum   = "maximum"
+	EdgeMinLength = "minLength"
+	EdgeMaxLength = "maxLength"
+	EdgeZero      = "zero"
+	EdgeNegative  = "negative"
+	EdgeLarge     = "large"
+	EdgeEmpty     = "empty"
+	EdgeLong      = "long"
+	EdgeUnicode   = "unicode"
+	EdgeCalendar  = "calendar"
+	EdgeTagged    = "tagged"
+)
+
+// epoch is the earliest generated date; dates fall within the year after it
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// booleanNameRegex matches flag-style names such as isActive or has_stock
+var booleanNameRegex = regexp.MustCompile(`^(?:is|has|can|should)(?:[A-Z_]|$)`)
+
+// formats maps formats and format-like types to the kinds generated for them
+var formats = map[string]string{
+	"email":     "email",
+	"uuid":      "uuid",
+	"date":      "date",
+	"date-time": "datetime",
+	"datetime":  "datetime",
+	"url":       "url",
+	"uri":       "url",
+	"phone":     "phone",
+	"e164":      "phone",
+	"color":     "color",
+	"ip":        "ip",
+	"ipv4":      "ip",
+	"ipv6":      "ipv6",
+}
+
+// Case is a generated value and what it exercises
+type Case struct {
+	Value interface{} `json:"value"`
+	// Rule is, for an invalid value, the errparse rule the target is expected
+	// to reject it with and, for an edge case, the Edge label of the boundary
+	Rule string `json:"rule"`
+}
+
+// Generator produces values. It is safe for concurrent use.
+type Generator struct {
+	mu    sync.Mutex
+	seed  int64
+	draws map[string]uint64 // values generated so far per field name
+	nonce int               // not seeded, so Fresh values differ between generators
+	seq   int               // values handed out by Fresh
+}
+
+// New creates a generator. The same seed gives the same values for each
+// field, except those of Fresh.
+func New(seed int64) *Generator {
+	return &Generator{seed: seed, draws: make(map[string]uint64), nonce: 1 + rand.IntN(999999)}
+}
+
+// rngFor returns the random source of the next value for a field, derived
+// from the seed, the field's name and the values drawn for it so far
+func (g *Generator) rngFor(name string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	h.Write(binary.BigEndian.AppendUint64([]byte{0}, g.draws[name]))
+	g.draws[name]++
+	return rand.New(rand.NewPCG(uint64(g.seed), h.Sum64()))
+}
+
+// Seed returns the seed the generator was created with
+func (g *Generator) Seed() int64 {
+	return g.seed
+}
+
+// Valid returns a value the field should accept: an enum value if the field
+// has an enum, otherwise a value of its format or type within its bounds.
+// Patterns are not generated from.
+func (g *Generator) Valid(info models.FieldInfo) interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return valid(g.rngFor(info.Name), info)
+}
+
+func valid(rng *rand.Rand, info models.FieldInfo) interface{} {
+	if len(info.Enum) > 0 {
+		return EnumValue(info.Type, info.Enum[0])
+	}
+
+	lower := strings.ToLower(info.Name)
+	switch kind(info) {
+	case "email":
+		return fmt.Sprintf("user%d@example.com", rng.IntN(100000))
+	case "uuid":
+		return uuid(rng)
+	case "date":
+		return epoch.AddDate(0, 0, rng.IntN(365)).Format("2006-01-02")
+	case "datetime":
+		return epoch.Add(time.Duration(rng.Int64N(365*24*3600)) * time.Second).Format(time.RFC3339)
+	case "url":
+		return fmt.Sprintf("https://example.com/item%d", rng.IntN(10000))
+	case "phone":
+		// 555-01xx numbers are reserved for fiction
+		return fmt.Sprintf("+1202555%04d", 100+rng.IntN(100))
+	case "color":
+		return fmt.Sprintf("#%06x", rng.IntN(1<<24))
+	case "ip":
+		// TEST-NET-1, reserved for documentation
+		return fmt.Sprintf("192.0.2.%d", 1+rng.IntN(254))
+	case "ipv6":
+		return fmt.Sprintf("2001:db8::%x", 1+rng.IntN(0xffff))
+	case "boolean":
+		return true
+	case "integer", "year", "timestamp":
+		lo, hi := integerRange(info, lower)
+		return float64(lo + rng.Int64N(hi-lo+1))
+	case "number":
+		lo, hi := numberRange(info, lower)
+		return math.Max(lo, math.Min(hi, math.Round((lo+rng.Float64()*(hi-lo))*100)/100))
+	case "array":
+		if item, ok := itemInfo(info); ok {
+			return []interface{}{valid(rng, item)}
+		}
+		return []interface{}{"example"}
+	case "object":
+		return map[string]interface{}{}
+	default:
+		return fitLength(fmt.Sprintf("example%d", rng.IntN(10000)), info)
+	}
+}
+
+// Edges returns values the field should accept that sit on the boundaries of
+// its constraints, or are unusual for its kind
+func (g *Generator) Edges(info models.FieldInfo) []Case {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	rng := g.rngFor(info.Name)
+
+	var cases []Case
+	add := func(value interface{}, rule string) {
+		cases = append(cases, Case{Value: value, Rule: rule})
+	}
+	if len(info.Enum) > 0 {
+		for _, entry := range info.Enum[1:] {
+			add(EnumValue(info.Type, entry), EdgeEnum)
+		}
+		return cases
+	}
+
+	switch kind(info) {
+	case "integer", "year", "timestamp", "number":
+		if info.Minimum != nil {
+			add(*info.Minimum, EdgeMinimum)
+		}
+		if info.Maximum != nil {
+			add(*info.Maximum, EdgeMaximum)
+		}
+		if info.Minimum == nil && info.Maximum == nil {
+			add(0.0, EdgeZero)
+			add(-1.0, EdgeNegative)
+			add(float64(math.MaxInt32), EdgeLarge)
+		}
+	case "string":
+		if info.MinLength != nil {
+			add(strings.Repeat("x", *info.MinLength), EdgeMinLength)
+		} else {
+			add("", EdgeEmpty)
+		}
+		if info.MaxLength != nil {
+			add(strings.Repeat("x", *info.MaxLength), EdgeMaxLength)
+		} else {
+			add(strings.Repeat("x", longStringLength), EdgeLong)
+		}
+		add(fitLength(unicodeText, info), EdgeUnicode)
+	case "email":
+		add(fmt.Sprintf("user%d+tag@mail.example.com", rng.IntN(100000)), EdgeTagged)
+	case "date":
+		add("2024-02-29", EdgeCalendar)
+	case "datetime":
+		add("2024-02-29T23:59:59+05:30", EdgeCalendar)
+	case "array":
+		add([]interface{}{}, EdgeEmpty)
+	}
+	return cases
+}
+
+// Invalid returns values the field should reject, each with the rule the
+// target is expected to report
+func (g *Generator) Invalid(info models.FieldInfo) []Case {
+	var cases []Case
+	add := func(value interface{}, rule string) {
+		cases = append(cases, Case{Value: value, Rule: rule})
+	}
+
+	k := kind(info)
+	switch k {
+	case "integer", "year", "timestamp":
+		add("not-a-number", errparse.RuleType)
+		add(1.5, errparse.RuleType)
+	case "number":
+		add("not-a-number", errparse.RuleType)
+	case "boolean":
+		add("not-a-boolean", errparse.RuleType)
+	case "array":
+		add("not-an-array", errparse.RuleType)
+	case "object":
+		add("not-an-object", errparse.RuleType)
+	default:
+		add(12345.0, errparse.RuleType)
+	}
+
+	switch k {
+	case "email":
+		add("not-an-email", errparse.RuleFormat)
+	case "uuid":
+		add("not-a-uuid", errparse.RuleFormat)
+	case "date":
+		add("2024-13-45", errparse.RuleFormat)
+	case "datetime":
+		add("not-a-date-time", errparse.RuleFormat)
+	case "url":
+		add("not a url", errparse.RuleFormat)
+	case "phone":
+		add("12", errparse.RuleFormat)
+	case "color":
+		add("not-a-color", errparse.RuleFormat)
+	case "ip", "ipv6":
+		add("999.999.999.999", errparse.RuleFormat)
+	}
+
+	if len(info.Enum) > 0 {
+		add(outsideEnum(info), errparse.RuleEnum)
+	}
+	step := 0.01
+	if k == "integer" || k == "year" || k == "timestamp" {
+		step = 1
+	}
+	if info.Minimum != nil {
+		add(*info.Minimum-step, errparse.RuleMin)
+	}
+	if info.Maximum != nil {
+		add(*info.Maximum+step, errparse.RuleMax)
+	}
+	if info.MinLength != nil && *info.MinLength > 0 {
+		add(strings.Repeat("x", *info.MinLength-1), errparse.RuleMinLength)
+	}
+	if info.MaxLength != nil {
+		add(strings.Repeat("x", *info.MaxLength+1), errparse.RuleMaxLength)
+	}
+	return cases
+}
+
+// Fresh returns a value of the same kind as value that the generator has not
+// produced before, for fields that must be unique. Emails keep their domain
+// and gain a plus tag. Fresh values are not seeded: they include a nonce of
+// the generator, so runs with the same seed against a target that keeps its
+// data do not collide with each other's unique values.
+func (g *Generator) Fresh(value interface{}) (interface{}, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seq++
+	suffix := fmt.Sprintf("%d-%d", g.nonce, g.seq)
+
+	switch v := value.(type) {
+	case string:
+		if local, domain, ok := strings.Cut(v, "@"); ok {
+			if base, _, tagged := strings.Cut(local, "+"); tagged {
+				local = base
+			}
+			return local + "+" + suffix + "@" + domain, true
+		}
+		return v + "-" + suffix, true
+	case float64:
+		return v + float64(g.nonce*1000+g.seq), true
+	case int:
+		return v + g.nonce*1000 + g.seq, true
+	default:
+		return nil, false
+	}
+}
+
+// kind decides what to generate for a field: its format if it has a known
+// one, otherwise its type, otherwise what its name suggests
+func kind(info models.FieldInfo) string {
+	fieldType := strings.ToLower(info.Type)
+	if k, ok := formats[strings.ToLower(info.Format)]; ok {
+		return k
+	}
+	if k, ok := formats[fieldType]; ok {
+		return k
+	}
+	switch {
+	case strings.HasPrefix(fieldType, "array"):
+		return "array"
+	case fieldType == "integer" || fieldType == "year" || fieldType == "timestamp":
+		return fieldType
+	case fieldType == "number" || fieldType == "float" || fieldType == "currency" || fieldType == "percentage":
+		return "number"
+	case fieldType == "boolean" || fieldType == "object":
+		return fieldType
+	case fieldType != "" && fieldType != "string":
+		return "string"
+	}
+
+	// Untyped fields and plain strings are guessed from the name
+	lower := strings.ToLower(info.Name)
+	switch {
+	case strings.Contains(lower, "email"):
+		return "email"
+	case strings.Contains(lower, "url"):
+		return "url"
+	case strings.Contains(lower, "phone"):
+		return "phone"
+	case strings.Contains(lower, "date"):
+		return "date"
+	case fieldType == "string":
+		return "string"
+	case booleanNameRegex.MatchString(info.Name):
+		return "boolean"
+	case lower == "age" || strings.HasSuffix(lower, "count") || strings.HasSuffix(lower, "quantity"):
+		return "integer"
+	case strings.Contains(lower, "price") || strings.Contains(lower, "amount"):
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// integerRange returns the bounds of generated integers
+func integerRange(info models.FieldInfo, lower string) (int64, int64) {
+	lo, hi := int64(1), int64(100)
+	switch {
+	case info.Type == "year":
+		lo, hi = 2000, 2024
+	case info.Type == "timestamp":
+		lo, hi = epoch.Unix(), epoch.AddDate(1, 0, 0).Unix()
+	case lower == "age":
+		lo, hi = 18, 80
+	}
+	if info.Minimum != nil {
+		lo = int64(math.Ceil(*info.Minimum))
+		if info.Maximum == nil || *info.Maximum >= float64(lo+100) {
+			hi = lo + 100
+		}
+	}
+	if info.Maximum != nil {
+		hi = int64(math.Floor(*info.Maximum))
+		if info.Minimum == nil && hi < lo {
+			lo = hi - 100
+		}
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// numberRange returns the bounds of generated numbers
+func numberRange(info models.FieldInfo, lower string) (float64, float64) {
+	lo, hi := 1.0, 100.0
+	if strings.Contains(lower, "percent") || info.Type == "percentage" {
+		lo, hi = 0, 100
+	}
+	if info.Minimum != nil {
+		lo = *info.Minimum
+		if info.Maximum == nil {
+			hi = lo + 100
+		}
+	}
+	if info.Maximum != nil {
+		hi = *info.Maximum
+		if info.Minimum == nil && hi < lo {
+			lo = hi - 100
+		}
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// itemInfo returns the item field of an array<T> field
+func itemInfo(info models.FieldInfo) (models.FieldInfo, bool) {
+	inner, ok := strings.CutPrefix(info.Type, "array<")
+	if !ok {
+		return models.FieldInfo{}, false
+	}
+	return models.FieldInfo{Name: info.Name, Type: strings.TrimSuffix(inner, ">")}, true
+}
+
+// fitLength pads or cuts a string to the field's length bounds, in runes
+func fitLength(s string, info models.FieldInfo) string {
+	runes := []rune(s)
+	if info.MaxLength != nil && len(runes) > *info.MaxLength {
+		runes = runes[:*info.MaxLength]
+	}
+	if info.MinLength != nil && len(runes) < *info.MinLength {
+		runes = append(runes, []rune(strings.Repeat("x", *info.MinLength-len(runes)))...)
+	}
+	return string(runes)
+}
+
+// EnumValue converts an enum entry, which is kept as text, to the field's type
+func EnumValue(fieldType, entry string) interface{} {
+	switch fieldType {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(entry, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(entry); err == nil {
+			return b
+		}
+	}
+	return entry
+}
+
+// outsideEnum returns a value of the field's type that is not in its enum
+func outsideEnum(info models.FieldInfo) interface{} {
+	if info.Type != "integer" && info.Type != "number" {
+		return NotInEnum
+	}
+	highest := math.Inf(-1)
+	for _, entry := range info.Enum {
+		if n, err := strconv.ParseFloat(entry, 64); err == nil && n > highest {
+			highest = n
+		}
+	}
+	if math.IsInf(highest, -1) {
+		return NotInEnum
+	}
+	return highest + 1
+}
+
+// uuid returns a random version 4 UUID
+func uuid(rng *rand.Rand) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], rng.Uint64())
+	binary.BigEndian.PutUint64(b[8:], rng.Uint64())
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
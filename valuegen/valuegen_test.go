@@ -0,0 +1,141 @@
+package valuegen
+
+import (
+	"ai-agent-api-discovery/errparse"
+	"ai-agent-api-discovery/models"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// fieldsForTest cover every kind the generator produces
+var fieldsForTest = []models.FieldInfo{
+	{Name: "email"},
+	{Name: "id", Type: "uuid"},
+	{Name: "birthday", Type: "string", Format: "date"},
+	{Name: "createdAt", Format: "date-time"},
+	{Name: "homepage", Type: "url"},
+	{Name: "phone"},
+	{Name: "age", Type: "integer"},
+	{Name: "price", Type: "number"},
+	{Name: "tags", Type: "array<string>"},
+	{Name: "name", Type: "string"},
+	{Name: "isActive"},
+}
+
+func TestSameSeedSameValues(t *testing.T) {
+	generate := func(g *Generator, order []int) map[string][]interface{} {
+		values := make(map[string][]interface{})
+		for round := 0; round < 2; round++ {
+			for _, i := range order {
+				info := fieldsForTest[i]
+				values[info.Name] = append(values[info.Name], g.Valid(info))
+			}
+		}
+		return values
+	}
+	forward := make([]int, len(fieldsForTest))
+	backward := make([]int, len(fieldsForTest))
+	for i := range fieldsForTest {
+		forward[i], backward[len(fieldsForTest)-1-i] = i, i
+	}
+
+	first := generate(New(42), forward)
+	// The order fields are asked for does not change their values
+	if second := generate(New(42), backward); !reflect.DeepEqual(first, second) {
+		t.Errorf("same seed gave different values:\n%v\n%v", first, second)
+	}
+	if other := generate(New(43), forward); reflect.DeepEqual(first, other) {
+		t.Error("different seeds gave the same values")
+	}
+	// Values drawn again for a field differ from the first ones
+	if first["email"][0] == first["email"][1] {
+		t.Errorf("repeated values for email: %v", first["email"])
+	}
+}
+
+func TestValidFitsKindAndBounds(t *testing.T) {
+	min, max, minLength, maxLength := 5.0, 7.0, 12, 14
+	g := New(1)
+	tests := []struct {
+		info    models.FieldInfo
+		pattern string
+	}{
+		{models.FieldInfo{Name: "email"}, `^user\d+@example\.com$`},
+		{models.FieldInfo{Name: "id", Type: "uuid"}, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{models.FieldInfo{Name: "birthday", Format: "date"}, `^2024-\d\d-\d\d$|^2025-01-0\d$`},
+		{models.FieldInfo{Name: "phone"}, `^\+12025550[1]\d\d$`},
+		{models.FieldInfo{Name: "role", Type: "string", Enum: []string{"admin", "user"}}, `^admin$`},
+		{models.FieldInfo{Name: "code", Type: "string", MinLength: &minLength, MaxLength: &maxLength}, `^.{12,14}$`},
+	}
+	for _, tt := range tests {
+		value, ok := g.Valid(tt.info).(string)
+		if !ok || !regexp.MustCompile(tt.pattern).MatchString(value) {
+			t.Errorf("Valid(%s) = %#v, want %s", tt.info.Name, value, tt.pattern)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		n, ok := g.Valid(models.FieldInfo{Name: "count", Type: "integer", Minimum: &min, Maximum: &max}).(float64)
+		if !ok || n < min || n > max || n != float64(int(n)) {
+			t.Fatalf("Valid(count) = %v, want an integer in [5, 7]", n)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, g.Valid(models.FieldInfo{Name: "at", Format: "date-time"}).(string)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEdgesAndInvalid(t *testing.T) {
+	min, maxLength := 1.0, 3
+	g := New(1)
+	quantity := models.FieldInfo{Name: "quantity", Type: "integer", Minimum: &min}
+	if got := g.Edges(quantity); !reflect.DeepEqual(got, []Case{{1.0, EdgeMinimum}}) {
+		t.Errorf("Edges(quantity) = %v", got)
+	}
+	want := []Case{{"not-a-number", errparse.RuleType}, {1.5, errparse.RuleType}, {0.0, errparse.RuleMin}}
+	if got := g.Invalid(quantity); !reflect.DeepEqual(got, want) {
+		t.Errorf("Invalid(quantity) = %v, want %v", got, want)
+	}
+
+	code := models.FieldInfo{Name: "code", Type: "string", MaxLength: &maxLength}
+	want = []Case{{"", EdgeEmpty}, {"xxx", EdgeMaxLength}, {"Zoë", EdgeUnicode}}
+	if got := g.Edges(code); !reflect.DeepEqual(got, want) {
+		t.Errorf("Edges(code) = %v, want %v", got, want)
+	}
+
+	level := models.FieldInfo{Name: "level", Type: "integer", Enum: []string{"1", "2", "5"}}
+	if got := g.Edges(level); !reflect.DeepEqual(got, []Case{{2.0, EdgeEnum}, {5.0, EdgeEnum}}) {
+		t.Errorf("Edges(level) = %v", got)
+	}
+	if got := g.Invalid(level); got[len(got)-1] != (Case{6.0, errparse.RuleEnum}) {
+		t.Errorf("Invalid(level) = %v, want 6 outside the enum", got)
+	}
+}
+
+func TestFreshDiffersAcrossGenerators(t *testing.T) {
+	first, second := New(42), New(42)
+	for second.nonce == first.nonce {
+		second = New(42)
+	}
+	seen := make(map[interface{}]bool)
+	for _, g := range []*Generator{first, second} {
+		for i := 0; i < 3; i++ {
+			value, ok := g.Fresh("ada+old@example.com")
+			if !ok || seen[value] {
+				t.Fatalf("Fresh = %v, %v; want a new value", value, ok)
+			}
+			if !regexp.MustCompile(`^ada\+\d+-\d+@example\.com$`).MatchString(value.(string)) {
+				t.Errorf("Fresh email = %s", value)
+			}
+			seen[value] = true
+		}
+	}
+	if n, ok := first.Fresh(7.0); !ok || n == 7.0 {
+		t.Errorf("Fresh(7) = %v, %v", n, ok)
+	}
+	if _, ok := first.Fresh(true); ok {
+		t.Error("Fresh(true) gave a value")
+	}
+}